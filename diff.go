@@ -0,0 +1,221 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/djherbis/times"
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/parse"
+)
+
+// diffStatus is how a file changed between two runs.
+type diffStatus string
+
+const (
+	diffAdded diffStatus = "+"
+	diffRemoved diffStatus = "-"
+	diffChanged diffStatus = "~"
+)
+
+// These explain why a file was removed from the list between two runs.
+const (
+	removedDeleted = "deleted"
+	removedAccessed = "accessed"
+	removedOther = "not selected"
+)
+
+// diffEntry is a file which was added, removed or changed between two runs.
+// Removed files also have the reason they were removed.
+type diffEntry struct {
+	Status diffStatus
+	Path string
+	OldSize int64
+	NewSize int64
+	Reason string
+}
+
+// Delta returns the change in the size of the file between the two runs.
+func (e diffEntry) Delta() int64 {
+	return e.NewSize - e.OldSize
+}
+
+// diff executes the 'diff' command.
+func diff(c *cli.Context) (err error) {
+	oldRun, err := readSavedRun(c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	newRun, err := readSavedRun(c.Args()[1])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	entries := diffRuns(oldRun, newRun)
+	quote := terminalQuoter()
+	if len(entries) > 0 {
+		printDiff(os.Stdout, entries, quote)
+		fmt.Println()
+		printDirDeltas(os.Stdout, entries, quote)
+		fmt.Println()
+	}
+
+	var added, removed, changed int
+	for _, entry := range entries {
+		switch entry.Status {
+		case diffAdded:
+			added++
+		case diffRemoved:
+			removed++
+		case diffChanged:
+			changed++
+		}
+	}
+	fmt.Printf(
+		"%d added, %d removed, %d changed, %s net (%s to %s)\n",
+		added, removed, changed,
+		formatDelta(newRun.Summary.TotalSize - oldRun.Summary.TotalSize),
+		parse.FormatFileSize(oldRun.Summary.TotalSize),
+		parse.FormatFileSize(newRun.Summary.TotalSize))
+
+	return nil
+}
+
+// readSavedRun reads a list of files saved with 'list --save'.
+func readSavedRun(path string) (*jsonOutput, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var run jsonOutput
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("the file '%s' is not a saved list: %v", path, err)
+	}
+	if run.SchemaVersion != jsonSchemaVersion {
+		return nil, fmt.Errorf("the file '%s' has the unsupported schema version %d", path, run.SchemaVersion)
+	}
+	return &run, nil
+}
+
+// diffRuns returns the files which were added, removed or changed between
+// oldRun and newRun sorted by path. A file changed if its size or modification
+// time changed. Removed files are checked to see whether they were deleted or
+// accessed since oldRun.
+func diffRuns(oldRun, newRun *jsonOutput) []diffEntry {
+	oldFiles := make(map[string]jsonFile)
+	for _, file := range oldRun.Files {
+		oldFiles[file.Path] = file
+	}
+
+	var entries []diffEntry
+	newFiles := make(map[string]bool)
+	for _, newFile := range newRun.Files {
+		newFiles[newFile.Path] = true
+		oldFile, ok := oldFiles[newFile.Path]
+		if !ok {
+			entries = append(entries, diffEntry{diffAdded, newFile.Path, 0, newFile.Size, ""})
+		} else if oldFile.Size != newFile.Size || !oldFile.Mtime.Equal(newFile.Mtime) {
+			entries = append(entries, diffEntry{diffChanged, newFile.Path, oldFile.Size, newFile.Size, ""})
+		}
+	}
+	for _, oldFile := range oldRun.Files {
+		if !newFiles[oldFile.Path] {
+			entries = append(entries, diffEntry{diffRemoved, oldFile.Path, oldFile.Size, 0, removalReason(oldFile)})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// removalReason returns why file, which was in an earlier run, is no longer in
+// the list.
+func removalReason(file jsonFile) string {
+	info, err := os.Lstat(file.Path)
+	if os.IsNotExist(err) {
+		return removedDeleted
+	}
+	if err == nil && times.Get(info).AccessTime().After(file.Atime) {
+		return removedAccessed
+	}
+	return removedOther
+}
+
+// printDiff prints a formatted table of entries to output.
+func printDiff(output io.Writer, entries []diffEntry, quote func(string) string) {
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, " \tSize\tDelta\tReason\tPath")
+	for _, entry := range entries {
+		size := entry.NewSize
+		if entry.Status == diffRemoved {
+			size = entry.OldSize
+		}
+		fmt.Fprintf(
+			writer, "%s\t%s\t%s\t%s\t%s\n",
+			entry.Status, parse.FormatFileSize(size), formatDelta(entry.Delta()), entry.Reason, quote(entry.Path))
+	}
+	writer.Flush()
+}
+
+// printDirDeltas prints a formatted table of the net change in size of the
+// files in entries in each directory to output. Directories are sorted from
+// the one that grew the most to the one that shrank the most.
+func printDirDeltas(output io.Writer, entries []diffEntry, quote func(string) string) {
+	deltas := make(map[string]int64)
+	for _, entry := range entries {
+		deltas[filepath.Dir(entry.Path)] += entry.Delta()
+	}
+	dirs := make([]string, 0, len(deltas))
+	for dir := range deltas {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if deltas[dirs[i]] != deltas[dirs[j]] {
+			return deltas[dirs[i]] > deltas[dirs[j]]
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, "Delta\tDirectory")
+	for _, dir := range dirs {
+		fmt.Fprintf(writer, "%s\t%s\n", formatDelta(deltas[dir]), quote(dir + string(filepath.Separator)))
+	}
+	writer.Flush()
+}
+
+// formatDelta formats a change in a number of bytes with a sign.
+func formatDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + parse.FormatFileSize(-delta)
+	}
+	return "+" + parse.FormatFileSize(delta)
+}
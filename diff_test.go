@@ -0,0 +1,103 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffRuns(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "reddup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempPath)
+
+	// Files which were removed from the list are labeled by whether they
+	// still exist and whether they were accessed since the old run.
+	oldTime := time.Now().Add(-time.Hour)
+	newTime := time.Now()
+	for _, name := range []string{"accessed", "unchanged"} {
+		if err := ioutil.WriteFile(filepath.Join(tempPath, name), []byte("a"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chtimes(filepath.Join(tempPath, "accessed"), newTime, oldTime)
+	os.Chtimes(filepath.Join(tempPath, "unchanged"), oldTime, oldTime)
+
+	file := func(name string, size int64, mtime time.Time) jsonFile {
+		return jsonFile{Path: filepath.Join(tempPath, name), Size: size, Atime: oldTime, Mtime: mtime}
+	}
+
+	testCases := []struct {
+		TestName string
+		OldFiles []jsonFile
+		NewFiles []jsonFile
+		Expected []diffEntry
+	}{
+		{
+			"Unchanged",
+			[]jsonFile{file("same", 10, oldTime)},
+			[]jsonFile{file("same", 10, oldTime)},
+			nil,
+		},
+		{
+			"Added",
+			nil,
+			[]jsonFile{file("added", 10, oldTime)},
+			[]diffEntry{{diffAdded, filepath.Join(tempPath, "added"), 0, 10, ""}},
+		},
+		{
+			"Changed",
+			[]jsonFile{file("size", 10, oldTime), file("mtime", 10, oldTime)},
+			[]jsonFile{file("size", 15, oldTime), file("mtime", 10, newTime)},
+			[]diffEntry {
+				{diffChanged, filepath.Join(tempPath, "mtime"), 10, 10, ""},
+				{diffChanged, filepath.Join(tempPath, "size"), 10, 15, ""},
+			},
+		},
+		{
+			"Removed",
+			[]jsonFile{file("deleted", 10, oldTime), file("accessed", 1, oldTime), file("unchanged", 1, oldTime)},
+			nil,
+			[]diffEntry {
+				{diffRemoved, filepath.Join(tempPath, "accessed"), 1, 0, removedAccessed},
+				{diffRemoved, filepath.Join(tempPath, "deleted"), 10, 0, removedDeleted},
+				{diffRemoved, filepath.Join(tempPath, "unchanged"), 1, 0, removedOther},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			oldRun := &jsonOutput{Files: tc.OldFiles}
+			newRun := &jsonOutput{Files: tc.NewFiles}
+			entries := diffRuns(oldRun, newRun)
+			if !reflect.DeepEqual(entries, tc.Expected) {
+				t.Errorf("%v != %v", entries, tc.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// dupes executes the 'dupes' command.
+func dupes(c *cli.Context) (err error) {
+	startDir := c.Args()[0]
+	result, nonExcludedPaths := scanPaths(c, startDir)
+
+	// Find groups of duplicates and mark which copy is kept in each one.
+	// Redundant directories are reported as single groups, and files which
+	// are backed up are grouped with their copy in a reference tree.
+	result.FindDuplicates = true
+	result.BackedUpGroups, result.DuplicateDirGroups, result.DuplicateGroups = findDuplicateGroups(
+		c, result, nonExcludedPaths)
+	paths.KeepDuplicates(result.DuplicateGroups, getDuplicateKeepRules(c, result))
+	var groups []paths.FilePaths
+	for _, group := range reclaimableGroups(result) {
+		if paths.CheckSpan(group, c.StringSlice("span")) {
+			groups = append(groups, group)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return paths.WastedSize(groups[j]) < paths.WastedSize(groups[i])
+	})
+
+	if len(groups) > 0 {
+		printDuplicateGroups(os.Stdout, groups)
+		fmt.Println()
+	}
+
+	// Print a summary of the reclaimable space.
+	var numCopies int
+	var totalWasted int64
+	for _, group := range groups {
+		numCopies += len(group) - 1
+		totalWasted += paths.WastedSize(group)
+	}
+	fmt.Printf(
+		"%d groups, %d redundant copies, %s reclaimable\n",
+		len(groups), numCopies, parse.FormatFileSize(totalWasted))
+
+	return nil
+}
+
+// printDuplicateGroups prints a formatted table of each group of duplicates in
+// groups to output. This includes the number of bytes wasted by each group and
+// the size and path of each copy. The first copy in each group is marked as
+// kept.
+func printDuplicateGroups(output io.Writer, groups []paths.FilePaths) {
+	quote := terminalQuoter()
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, "#\tWasted\tCopies\tSize\tPath")
+
+	for i, group := range groups {
+		for j, filePath := range group {
+			if j == 0 {
+				fmt.Fprintf(
+					writer, "%d\t%s\t%d\t%s\t%s (kept)\n",
+					i + 1,
+					parse.FormatFileSize(paths.WastedSize(group)),
+					len(group),
+					parse.FormatFileSize(filePath.Size()),
+					displayPath(filePath, quote))
+			} else {
+				fmt.Fprintf(writer, "\t\t\t\t%s\n", displayPath(filePath, quote))
+			}
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,157 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// defaultEditor is the editor which is used if $VISUAL and $EDITOR are unset.
+const defaultEditor = "vi"
+
+// editHeader is written at the top of the file that the user edits.
+const editHeader = `# Files to move. Delete a line or comment it out with '#' to keep the file.
+# Each line contains the rank, the size and the path of a file. Only the path
+# is read back, and it must be one of the paths below.
+#
+`
+
+// editPaths writes files to a temporary file, opens it in the user's editor
+// and returns the files whose lines remain after the editor exits.
+func editPaths(files paths.FilePaths) (paths.FilePaths, error) {
+	tempFile, err := ioutil.TempFile("", "reddup-move-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	err = writeEditList(tempFile, files)
+	tempFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	// The editor is run through the shell so that it can include arguments.
+	cmd := exec.Command("sh", "-c", editor + ` "$1"`, "sh", tempFile.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("the editor '%s' failed: %v", editor, err)
+	}
+
+	editedFile, err := os.Open(tempFile.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer editedFile.Close()
+
+	return readEditList(editedFile, files)
+}
+
+// writeEditList writes a line for each file in files to output.
+func writeEditList(output io.Writer, files paths.FilePaths) error {
+	if _, err := io.WriteString(output, editHeader); err != nil {
+		return err
+	}
+	for _, filePath := range files {
+		_, err := fmt.Fprintf(
+			output, "%d %s %s\n",
+			filePath.Metadata.Rank, parse.FormatFileSize(filePath.Size()), parse.QuoteC(filePath.Path))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readEditList reads the lines written by writeEditList from input after they
+// were edited and returns the files in files whose paths remain. Blank lines
+// and lines starting with '#' are ignored. If any line doesn't contain one of
+// the paths in files, an error is returned.
+func readEditList(input io.Reader, files paths.FilePaths) (paths.FilePaths, error) {
+	candidates := make(map[string]int)
+	for i, filePath := range files {
+		candidates[filePath.Path] = i
+	}
+
+	chosen := make(map[int]bool)
+	var invalid []string
+	scanner := bufio.NewScanner(input)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		// Trailing spaces are kept because they may be part of the path.
+		line := strings.TrimLeft(strings.TrimSuffix(scanner.Text(), "\r"), " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 {
+			invalid = append(invalid, fmt.Sprintf("line %d: expected a rank, a size and a path", lineNum))
+			continue
+		}
+		path := fields[2]
+		if strings.HasPrefix(path, `"`) {
+			unquoted, err := strconv.Unquote(path)
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("line %d: the path %s is not quoted correctly", lineNum, path))
+				continue
+			}
+			path = unquoted
+		}
+
+		i, ok := candidates[path]
+		if !ok {
+			invalid = append(invalid, fmt.Sprintf("line %d: %s was not in the list of files", lineNum, parse.QuoteC(path)))
+			continue
+		}
+		chosen[i] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("nothing was moved because of these lines:\n%s", strings.Join(invalid, "\n"))
+	}
+
+	var selected paths.FilePaths
+	for i, filePath := range files {
+		if chosen[i] {
+			selected = append(selected, filePath)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadEditList(t *testing.T) {
+	names := []string{"letters/a.txt", "letters/b c.txt", "numbers/1.txt"}
+	files, teardownFunc := setupTestFiles(t, names...)
+	defer teardownFunc()
+
+	var written bytes.Buffer
+	if err := writeEditList(&written, files); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(written.String(), "\n"), "\n")
+	fileLines := lines[len(lines) - len(files):]
+
+	testCases := []struct {
+		TestName string
+		Input string
+		Expected []string
+		ErrorExpected bool
+	}{
+		{"Unchanged", written.String(), names, false},
+		{"Line deleted", fileLines[0] + "\n" + fileLines[2] + "\n", []string{"letters/a.txt", "numbers/1.txt"}, false},
+		{"Line commented out", "#" + fileLines[0] + "\n" + fileLines[1] + "\n", []string{"letters/b c.txt"}, false},
+		{"Blank lines", "\n  \n" + fileLines[1] + "\r\n", []string{"letters/b c.txt"}, false},
+		{"Everything deleted", "", nil, false},
+		{"Unknown path", fileLines[0] + "\n1 1B /etc/passwd\n", nil, true},
+		{"Changed path", strings.Replace(fileLines[2], "1.txt", "2.txt", 1) + "\n", nil, true},
+		{"Missing fields", "/etc/passwd\n", nil, true},
+		{"Bad quoting", "1 1B \"unterminated\n", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			selected, err := readEditList(strings.NewReader(tc.Input), files)
+			assertError(t, err, tc.ErrorExpected)
+			if err != nil {
+				return
+			}
+			assertSelected(t, selected, files, names, tc.Expected)
+		})
+	}
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// explain executes the 'explain' command.
+func explain(c *cli.Context) (err error) {
+	result := selectPaths(c)
+
+	decisions := paths.ExplainFilter(result.OtherPaths, result.MaxSize, result.MinDuration)
+	quotaDecisions := paths.ExplainFilterQuotas(
+		result.QuotaPaths, result.Quotas.Reclaim(result.DuplicatePaths), result.MinDuration)
+	for path, decision := range quotaDecisions {
+		decisions[path] = decision
+	}
+
+	// Index scanned paths by their absolute path so that they can be found no
+	// matter how they were specified.
+	scannedPaths := make(map[string]paths.FilePath)
+	for _, filePath := range result.AllPaths {
+		absPath, err := filepath.Abs(filePath.Path)
+		if err != nil {
+			return err
+		}
+		scannedPaths[absPath] = filePath
+	}
+
+	for i, arg := range c.Args()[2:] {
+		absPath, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		filePath, scanned := scannedPaths[absPath]
+		if !scanned {
+			fmt.Println(arg)
+			fmt.Println("    Scanned: no, it is not a regular file in the source directory")
+			continue
+		}
+		printExplanation(os.Stdout, filePath, result, decisions)
+	}
+
+	return nil
+}
+
+// printExplanation prints to output why the scanned file filePath was or
+// wasn't selected to be cleaned up.
+func printExplanation(output io.Writer, filePath paths.FilePath, result *selection, decisions map[string]paths.FilterDecision) {
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, filePath.Path)
+	fmt.Fprintln(writer, "    Scanned:\tyes")
+
+	// Check exclude patterns.
+	pattern, excluded := result.Exclude.MatchingPattern(filePath.Path, result.StartDir)
+	if excluded {
+		fmt.Fprintf(writer, "    Excluded:\tyes, by the pattern '%s'\n", pattern)
+	} else {
+		fmt.Fprintln(writer, "    Excluded:\tno")
+	}
+
+	// Check pins.
+	pinnedPath, pinned := result.Pins.MatchingPin(filePath.Path)
+	if pinned {
+		fmt.Fprintf(writer, "    Pinned:\tyes, by %s\n", pinnedPath)
+	} else {
+		fmt.Fprintln(writer, "    Pinned:\tno")
+	}
+
+	// Check duplicates. The first file in each group is the one that is kept.
+	var group paths.FilePaths
+	for _, duplicateGroup := range result.DuplicateGroups {
+		for _, duplicate := range duplicateGroup {
+			if duplicate.Path == filePath.Path {
+				group = duplicateGroup
+			}
+		}
+	}
+	dirGroup, dirIndex := findDirGroup(filePath, result.DuplicateDirGroups)
+	if !result.FindDuplicates {
+		fmt.Fprintln(writer, "    Duplicate:\tnot checked")
+	} else if dirIndex > 0 {
+		fmt.Fprintf(
+			writer, "    Duplicate:\tyes, in %s, which is found in %s, which is kept\n",
+			dirGroup[dirIndex].Path, dirGroup[0].Path)
+	} else if group == nil && dirIndex == 0 {
+		fmt.Fprintf(writer, "    Duplicate:\tyes, in %s, which is kept\n", dirGroup[0].Path)
+	} else if group == nil {
+		fmt.Fprintln(writer, "    Duplicate:\tno")
+	} else {
+		var others []string
+		for _, duplicate := range group {
+			if duplicate.Path != filePath.Path {
+				others = append(others, duplicate.Path)
+			}
+		}
+		if group[0].Path == filePath.Path {
+			fmt.Fprintf(writer, "    Duplicate:\tyes, kept over %s\n", strings.Join(others, ", "))
+		} else {
+			fmt.Fprintf(writer, "    Duplicate:\tyes, of %s, which is kept\n", group[0].Path)
+		}
+	}
+
+	// Check reference trees. The first file in each group is the copy in a
+	// reference tree.
+	inReference := paths.InReferences(filePath.Path, paths.ResolveReferences(result.References))
+	if len(result.References) > 0 {
+		var refCopy string
+		for _, backedUpGroup := range result.BackedUpGroups {
+			for _, backedUp := range backedUpGroup[1:] {
+				if backedUp.Path == filePath.Path {
+					refCopy = backedUpGroup[0].Path
+				}
+			}
+		}
+
+		if inReference {
+			fmt.Fprintln(writer, "    Backed up:\tno, it is in a reference tree")
+		} else if refCopy == "" {
+			fmt.Fprintln(writer, "    Backed up:\tno")
+		} else {
+			fmt.Fprintf(writer, "    Backed up:\tyes, by %s\n", refCopy)
+		}
+	}
+
+	// Check similar images. The first image in each group is the one that is
+	// kept.
+	if result.FindSimilar {
+		var similarGroup paths.FilePaths
+		for _, imageGroup := range result.SimilarGroups {
+			for _, similarImage := range imageGroup {
+				if similarImage.Path == filePath.Path {
+					similarGroup = imageGroup
+				}
+			}
+		}
+
+		if similarGroup == nil {
+			fmt.Fprintln(writer, "    Similar:\tno")
+		} else if similarGroup[0].Path == filePath.Path {
+			fmt.Fprintf(writer, "    Similar:\tyes, kept because it has the highest resolution of %d images\n", len(similarGroup))
+		} else {
+			fmt.Fprintf(writer, "    Similar:\tyes, to %s, which is kept\n", similarGroup[0].Path)
+		}
+	}
+
+	// Check companion files. Companions are considered as a single unit, which
+	// has the path of its main file.
+	unit := filePath
+	for _, candidate := range append(append(paths.FilePaths{}, result.OtherPaths...), result.QuotaPaths...) {
+		if candidate.Metadata.Contents == nil || candidate.Stat.IsDir() {
+			continue
+		}
+		for _, companion := range candidate.Metadata.Contents {
+			if companion.Path == filePath.Path {
+				unit = candidate
+			}
+		}
+	}
+	if unit.Metadata.Contents != nil {
+		var companions []string
+		for _, companion := range unit.Metadata.Contents {
+			if companion.Path != filePath.Path {
+				companions = append(companions, companion.Path)
+			}
+		}
+		fmt.Fprintf(writer, "    Companions:\t%s\n", strings.Join(companions, ", "))
+	}
+
+	// Check the filter expression.
+	matchesWhere := true
+	if result.Where != nil {
+		expressionPath := filePath
+		expressionPath.Metadata.Duplicate = dirIndex > 0 || (group != nil && group[0].Path != filePath.Path)
+		matchesWhere = result.Where.Evaluate(expressionPath, result.StartDir)
+		if matchesWhere {
+			fmt.Fprintln(writer, "    Where:\tpassed")
+		} else {
+			fmt.Fprintln(writer, "    Where:\tfailed")
+		}
+	}
+
+	// Check the priority and the minimum time.
+	// Files in a subtree with a quota are ranked separately from the others.
+	decision, considered := decisions[unit.Path]
+	if considered {
+		rankedPaths := result.OtherPaths
+		if result.Quotas.Match(unit.Path) >= 0 {
+			rankedPaths = result.QuotaPaths
+		}
+		fmt.Fprintf(
+			writer, "    Priority:\t%.0f (%d of %d)\n",
+			decision.Priority, decision.Position, len(rankedPaths))
+	} else {
+		fmt.Fprintln(writer, "    Priority:\tnot computed")
+	}
+	maxAtime := time.Now().Add(-result.MinDuration)
+	if unit.Time.AccessTime().After(maxAtime) {
+		fmt.Fprintf(writer, "    Min time:\tfailed, last accessed %s\n", unit.Time.AccessTime().Format(timeFormat))
+	} else {
+		fmt.Fprintf(writer, "    Min time:\tpassed, last accessed %s\n", unit.Time.AccessTime().Format(timeFormat))
+	}
+
+	// Check whether the file fit in the remaining space.
+	if considered {
+		switch decision.Status {
+		case paths.StatusOverBudget:
+			fmt.Fprintf(
+				writer, "    Budget:\t%v (%s left)\n",
+				decision.Status, parse.FormatFileSize(decision.Remaining))
+		default:
+			fmt.Fprintf(writer, "    Budget:\t%v\n", decision.Status)
+		}
+	} else if excluded {
+		fmt.Fprintln(writer, "    Budget:\tnot considered because it is excluded")
+	} else if pinned {
+		fmt.Fprintln(writer, "    Budget:\tnot considered because it is pinned")
+	} else if inReference {
+		fmt.Fprintln(writer, "    Budget:\tnot considered because it is in a reference tree")
+	} else if !matchesWhere {
+		fmt.Fprintln(writer, "    Budget:\tnot considered because it doesn't match the filter expression")
+	} else {
+		fmt.Fprintln(writer, "    Budget:\tnot considered because duplicates, backed up files and similar images don't count toward the size")
+	}
+
+	// Check whether the file was suggested.
+	rank := 0
+	for _, selectedPath := range result.Selected {
+		if selectedPath.Path == unit.Path || (dirIndex > 0 && selectedPath.Path == dirGroup[dirIndex].Path) {
+			rank = selectedPath.Metadata.Rank
+		}
+	}
+	if rank > 0 {
+		fmt.Fprintf(writer, "    Suggested:\tyes, #%d\n", rank)
+	} else {
+		fmt.Fprintln(writer, "    Suggested:\tno")
+	}
+
+	writer.Flush()
+}
+
+// findDirGroup returns the group of redundant directories containing a
+// directory which contains filePath and the index of that directory in the
+// group. Redundant directories are preferred over directories which are kept.
+// If no directory contains filePath, the index is -1.
+func findDirGroup(filePath paths.FilePath, dirGroups []paths.FilePaths) (dirGroup paths.FilePaths, index int) {
+	index = -1
+	for _, group := range dirGroups {
+		for i, dirPath := range group {
+			for _, contentPath := range dirPath.Metadata.Contents {
+				if contentPath.Path != filePath.Path {
+					continue
+				}
+				if i > 0 {
+					return group, i
+				}
+				dirGroup, index = group, i
+			}
+		}
+	}
+	return dirGroup, index
+}
@@ -29,8 +29,11 @@ import (
 	"os"
 	"io"
 	"strings"
+	"path/filepath"
 	"text/tabwriter"
 	"sort"
+	"time"
+	"runtime"
 
 	"github.com/urfave/cli"
 
@@ -67,6 +70,13 @@ Options:
 // This is the number of spaces of padding to put between columns in the output of "list."
 const listPadding = 2
 
+// This is the format used to print file times.
+const timeFormat = "Jan 02 2006 15:04"
+
+// stdinReader is used for all reads from stdin so that input which was
+// buffered by one read isn't lost by the next.
+var stdinReader = bufio.NewReader(os.Stdin)
+
 func main() {
 	cli.AppHelpTemplate = appHelpTemplate
 	cli.CommandHelpTemplate = commandHelpTemplate
@@ -108,10 +118,71 @@ func main() {
 			Usage: "Only include files which were last modified at least this much `<time>` in the past. This accepts the units 'h,' 'd,' 'm'  and 'y.'",
 			Value: "0h",
 		},
+		cli.StringFlag {
+			Name: "where",
+			Usage: "Only include files which match this filter `<expression>` (e.g. 'size > 1GiB && ext in [\"iso\"]').",
+		},
 		cli.BoolFlag {
 			Name: "no-duplicates",
 			Usage: "Don't automatically include duplicate files.",
 		},
+		cli.StringSliceFlag {
+			Name: "reference",
+			Usage: "Include files which have an identical copy in the directory `<dir>`, which is never cleaned up. This can be given multiple times.",
+		},
+		cli.BoolFlag {
+			Name: "no-duplicate-dirs",
+			Usage: "Don't handle directories whose files are all found in another directory as single units.",
+		},
+		cli.StringSliceFlag {
+			Name: "companions",
+			Usage: "Handle files in the same directory with the same name and an extension in this comma-separated `<set>` (e.g. 'cr2,jpg,xmp') as a single unit. This can be given multiple times.",
+		},
+		cli.BoolFlag {
+			Name: "similar-images",
+			Usage: "Also include JPEG, PNG and GIF images which look like another image with a higher resolution.",
+		},
+		cli.IntFlag {
+			Name: "similar-distance",
+			Usage: "Consider images similar if their perceptual hashes differ by at most this `<number>` of bits out of 64.",
+			Value: paths.NewSimilarImageFinder().MaxDistance,
+		},
+		cli.StringSliceFlag {
+			Name: "keep",
+			Usage: "Use this `<rule>` to choose which copy of a duplicate file to keep. This accepts 'newest,' 'oldest,' 'shortest-path,' 'longest-path,' 'most-links' and 'under:<dir>[:<dir>...].' Each rule breaks ties left by the ones before it. The default is 'newest.'",
+		},
+		cli.StringFlag {
+			Name: "hash",
+			Usage: "Use this `<algorithm>` to find duplicates. This accepts 'sha256' and 'crc64,' which is faster but not cryptographic.",
+			Value: string(paths.HashSHA256),
+		},
+		cli.BoolFlag {
+			Name: "verify-bytes",
+			Usage: "Compare duplicates byte for byte before including them.",
+		},
+		cli.BoolFlag {
+			Name: "cache-hashes",
+			Usage: "Store the hash of each file in the '" + paths.HashXattr + "' extended attribute and reuse it until the file changes.",
+		},
+		cli.IntFlag {
+			Name: "hash-workers",
+			Usage: "Hash up to this `<number>` of files at once when finding duplicates.",
+			Value: runtime.NumCPU(),
+		},
+		cli.IntFlag {
+			Name: "io-limit",
+			Usage: "Read up to this `<number>` of files at once when finding duplicates.",
+			Value: paths.NewDuplicateFinder().IOLimit,
+		},
+		cli.StringFlag {
+			Name: "pin-database",
+			Usage: "Store pins in this `<file>` on file systems which don't support extended attributes.",
+			Value: defaultPinDatabase(),
+		},
+		cli.StringFlag {
+			Name: "quota-file",
+			Usage: "Limit the size of subtrees to the quotas in this `<file>` instead of using <size> for them.",
+		},
 		cli.HelpFlag,
 	}
 
@@ -119,7 +190,7 @@ func main() {
 		cli.Command {
 			Name: "list",
 			Usage: "Print a list of files that should be cleaned up.",
-			Description: "Print a list of up to <size> bytes of files (e.g. 10GiB) in the directory <source> that should be cleaned up. For each file, also print its size, last access time and whether it is a duplicate.",
+			Description: "Print a list of up to <size> bytes of files (e.g. 10GiB) in the directory <source> that should be cleaned up. For each file, also print its size, last access time and whether it is a duplicate. For each duplicate, also print which copy is kept. If a quota file is given, also print the size of each subtree before and after cleaning up.",
 			ArgsUsage: "<size> <source>",
 			UseShortOptionHandling: true,
 			Flags: []cli.Flag{
@@ -127,6 +198,44 @@ func main() {
 					Name: "paths-only",
 					Usage: "Print only a list of newline-separated file paths.",
 				},
+				cli.StringFlag {
+					Name: "format",
+					Value: "table",
+					Usage: "Print the list in this `<format>`, which is 'table', 'json', 'ndjson' or 'template:' followed by a Go template which is printed for each file.",
+				},
+				cli.BoolFlag {
+					Name: "by-dir, tree",
+					Usage: "Print the total size and number of files to clean up in each directory instead of a list of files.",
+				},
+				cli.IntFlag {
+					Name: "depth",
+					Value: 1,
+					Usage: "Show directories up to this `<depth>` below the source directory with --by-dir.",
+				},
+				cli.StringFlag {
+					Name: "expand",
+					Usage: "Show only the directories in this subtree `<dir>` of the source directory with --by-dir.",
+				},
+				cli.BoolFlag {
+					Name: "null, 0",
+					Usage: "End each path with a NUL character instead of a newline when using --paths-only or a template.",
+				},
+				cli.StringFlag {
+					Name: "quote",
+					Usage: "Quote paths in the table and with --paths-only using this `<style>`, which is 'shell', 'c' or 'none'. The default is 'c' when printing to a terminal and 'none' otherwise.",
+				},
+				cli.StringFlag {
+					Name: "save",
+					Usage: "Also save the list as JSON to this `<file>` so that it can be compared with 'diff'.",
+				},
+				cli.StringFlag {
+					Name: "header",
+					Usage: "Print this Go `<template>` before the list when --format is a template.",
+				},
+				cli.StringFlag {
+					Name: "footer",
+					Usage: "Print this Go `<template>` after the list when --format is a template.",
+				},
 			},
 			Before: enforceArgs(2),
 			Action: list,
@@ -142,10 +251,156 @@ func main() {
 					Name: "no-prompt",
 					Usage: "Don't prompt the user for confirmation before moving files.",
 				},
+				cli.BoolFlag {
+					Name: "tui",
+					Usage: "Choose which files to move in a full-screen interface.",
+				},
+				cli.BoolFlag {
+					Name: "interactive, i",
+					Usage: "Ask whether to move each file one at a time.",
+				},
+				cli.BoolFlag {
+					Name: "edit, e",
+					Usage: "Choose which files to move by editing the list in $EDITOR.",
+				},
 			},
 			Before: enforceArgs(3),
 			Action: move,
 		},
+		cli.Command {
+			Name: "dupes",
+			Usage: "Print a list of groups of duplicate files.",
+			Description: "Print each group of duplicate files in the directory <source> with the size of each copy and the number of bytes wasted by the group. Groups are sorted by the number of bytes wasted, and the copy which would be kept is marked. Also print the total number of bytes which could be reclaimed.",
+			ArgsUsage: "<source>",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag {
+				cli.StringSliceFlag {
+					Name: "span",
+					Usage: "Only print groups with a copy under this `<dir>`. If this is given multiple times, groups must have a copy under each one.",
+				},
+			},
+			Before: enforceArgs(1),
+			Action: dupes,
+		},
+		cli.Command {
+			Name: "plan",
+			Usage: "Write a plan of files that should be cleaned up to be applied later.",
+			Description: "Write a plan of up to <size> bytes of files in the directory <source> that should be cleaned up as JSON. The plan includes the size, modification time, inode and hash of each file so that 'apply' can check whether it changed.",
+			ArgsUsage: "<size> <source>",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag {
+				cli.StringFlag {
+					Name: "output, o",
+					Usage: "Write the plan to this `<file>` instead of stdout.",
+				},
+				cli.BoolFlag {
+					Name: "no-hash",
+					Usage: "Don't record the hash of each file.",
+				},
+			},
+			Before: enforceArgs(2),
+			Action: makePlan,
+		},
+		cli.Command {
+			Name: "apply",
+			Usage: "Move the files in a plan.",
+			Description: "Move the files in the plan <file> written by 'plan' to <dest>. Files whose size, modification time or inode changed since the plan was written are skipped and reported instead of moved.",
+			ArgsUsage: "<file> <dest>",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag {
+				cli.BoolFlag {
+					Name: "verify-hash",
+					Usage: "Also skip files whose contents don't match the hash in the plan.",
+				},
+			},
+			Before: enforceArgs(2),
+			Action: applyPlan,
+		},
+		cli.Command {
+			Name: "diff",
+			Usage: "Compare two lists of files saved with 'list --save'.",
+			Description: "Print the files which were added to, removed from or changed between the lists <old> and <new> saved with 'list --save', the net change in size of each directory and the net change in the total size.",
+			ArgsUsage: "<old> <new>",
+			Before: enforceArgs(2),
+			Action: diff,
+		},
+		cli.Command {
+			Name: "stats",
+			Usage: "Print statistics about the files in a directory.",
+			Description: "Print the number and total size of the files in the directory <source>, histograms of bytes by last access time and of files by size, the extensions which use the most space, the bytes wasted by duplicates and the bytes which would be cleaned up at several sizes.",
+			ArgsUsage: "<source>",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag {
+				cli.StringFlag {
+					Name: "format",
+					Value: "table",
+					Usage: "Print the statistics in this `<format>`, which is 'table' or 'json'.",
+				},
+				cli.StringFlag {
+					Name: "budgets",
+					Value: "1GiB,10GiB,100GiB",
+					Usage: "Print the bytes that would be cleaned up at each of these comma-separated `<sizes>`.",
+				},
+				cli.IntFlag {
+					Name: "top",
+					Value: 10,
+					Usage: "Print this `<number>` of extensions.",
+				},
+			},
+			Before: enforceArgs(1),
+			Action: stats,
+		},
+		cli.Command {
+			Name: "explain",
+			Usage: "Explain why files were or weren't suggested to be cleaned up.",
+			Description: "For each <path>, explain whether it was scanned, excluded or found to be a duplicate, what its priority is and whether it was selected when suggesting up to <size> bytes of files in the directory <source>.",
+			ArgsUsage: "<size> <source> <path>...",
+			UseShortOptionHandling: true,
+			Before: enforceMinArgs(3),
+			Action: explain,
+		},
+		cli.Command {
+			Name: "pin",
+			Usage: "Pin files so that they are never suggested.",
+			Description: "Pin each <path> so that it is never suggested to be cleaned up. If <path> is a directory, nothing under it is suggested either.",
+			ArgsUsage: "<path>...",
+			UseShortOptionHandling: true,
+			Before: enforceMinArgs(1),
+			Action: pin,
+		},
+		cli.Command {
+			Name: "unpin",
+			Usage: "Unpin files that were previously pinned.",
+			Description: "Unpin each <path> so that it may be suggested to be cleaned up again.",
+			ArgsUsage: "<path>...",
+			UseShortOptionHandling: true,
+			Before: enforceMinArgs(1),
+			Action: unpin,
+		},
+		cli.Command {
+			Name: "pins",
+			Usage: "Print a list of pinned files.",
+			Description: "Print a list of every pinned file and directory in the directory <source>.",
+			ArgsUsage: "<source>",
+			UseShortOptionHandling: true,
+			Before: enforceArgs(1),
+			Action: listPins,
+		},
+		cli.Command {
+			Name: "apply-policy",
+			Usage: "Clean up files according to a policy file, prompting the user for confirmation first.",
+			Description: "Match the files in <source> against the rules in the policy <file> and take the action of the first rule that each file matches. Print the plan and prompt the user for confirmation before moving anything.",
+			ArgsUsage: "<file> <source>",
+			UseShortOptionHandling: true,
+			Flags: []cli.Flag {
+				cli.BoolFlag {
+					Name: "no-prompt",
+					Usage: "Don't prompt the user for confirmation before moving files.",
+				},
+			},
+			Before: enforceArgs(2),
+			Action: applyPolicy,
+		},
 		cli.Command {
 			Name: "help",
 			Usage: "Show a list of commands or help for one command.",
@@ -178,18 +433,121 @@ func enforceArgs(numArgs int) cli.BeforeFunc {
 	}
 }
 
+// enforceMinArgs returns a function that enforces a minimum number of
+// arguments.
+func enforceMinArgs(numArgs int) cli.BeforeFunc {
+	return func(c *cli.Context) error {
+		if len(c.Args()) < numArgs {
+			return fmt.Errorf("not enough arguments")
+		}
+		return nil
+	}
+}
+
 // list executes the 'list' command.
 func list(c *cli.Context) (err error) {
-	delPaths := getPaths(c)
+	format := c.String("format")
+	var templates outputTemplates
+	switch {
+	case format == "table", format == "json", format == "ndjson":
+		if c.String("header") != "" || c.String("footer") != "" {
+			return cli.NewExitError("--header and --footer can only be used with a template", 1)
+		}
+	case strings.HasPrefix(format, templateFormatPrefix):
+		templates, err = newOutputTemplates(
+			strings.TrimPrefix(format, templateFormatPrefix), c.String("header"), c.String("footer"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	default:
+		return cli.NewExitError(fmt.Sprintf(
+			"the format '%s' is not 'table', 'json', 'ndjson' or '%s<template>'", format, templateFormatPrefix), 1)
+	}
+	if c.Bool("paths-only") && format != "table" {
+		return cli.NewExitError("--paths-only can't be used with --format", 1)
+	}
+	if c.Bool("by-dir") && (c.Bool("paths-only") || format != "table") {
+		return cli.NewExitError("--by-dir can't be used with --paths-only or --format", 1)
+	}
+	if c.Int("depth") < 0 {
+		return cli.NewExitError("--depth can't be negative", 1)
+	}
+	if c.Bool("null") && !c.Bool("paths-only") && templates.File == nil {
+		return cli.NewExitError("--null can only be used with --paths-only or a template", 1)
+	}
+	quote, err := getQuoter(c.String("quote"), c.Bool("null"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	terminator := "\n"
+	if c.Bool("null") {
+		terminator = "\x00"
+	}
+
+	result := selectPaths(c)
+	delPaths, quotas := result.Selected, result.Quotas
+
+	if c.String("save") != "" {
+		saveFile, err := os.Create(c.String("save"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		err = printJSON(saveFile, result)
+		if closeErr := saveFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
 
-	if c.Bool("paths-only") {
+	if format == "json" {
+		if err := printJSON(os.Stdout, result); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	} else if format == "ndjson" {
+		if err := printNDJSON(os.Stdout, result); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	} else if templates.File != nil {
+		if err := printTemplate(os.Stdout, result, templates, terminator); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	} else if c.Bool("by-dir") {
+		root := result.StartDir
+		if c.String("expand") != "" {
+			root = c.String("expand")
+			if !filepath.IsAbs(root) {
+				root = filepath.Join(result.StartDir, root)
+			}
+			if relPath, err := filepath.Rel(result.StartDir, root); err != nil || relPath == ".." || strings.HasPrefix(relPath, ".." + string(filepath.Separator)) {
+				return cli.NewExitError(fmt.Sprintf("the directory '%s' is not in the source directory", c.String("expand")), 1)
+			}
+		}
+		totalSize := paths.RollupDirs(delPaths, result.StartDir, 0)[0].Size
+		printDirTotals(os.Stdout, paths.RollupDirs(delPaths, root, c.Int("depth")), totalSize, quote)
+	} else if c.Bool("paths-only") {
 		// Just print the file paths.
 		for _, filePath := range delPaths {
-			fmt.Println(filePath.Path)
+			if filePath.Metadata.Contents == nil || filePath.Stat.IsDir() {
+				fmt.Print(quote(filePath.Path), terminator)
+				continue
+			}
+			for _, companion := range filePath.Metadata.Contents {
+				fmt.Print(quote(companion.Path), terminator)
+			}
 		}
 	} else {
 		// Print additional information with the file paths.
-		printPaths(os.Stdout, delPaths)
+		printPaths(os.Stdout, delPaths, quote)
+		if hasDuplicates(delPaths) {
+			fmt.Println()
+			printKeptPaths(os.Stdout, delPaths, quote)
+		}
+		if len(quotas) > 0 {
+			fmt.Println()
+			printQuotas(os.Stdout, quotas, delPaths, quote)
+		}
 	}
 
 	return nil
@@ -197,7 +555,18 @@ func list(c *cli.Context) (err error) {
 
 // move executes the 'move' command.
 func move(c *cli.Context) (err error) {
-	delPaths := getPaths(c)
+	var modes []string
+	for _, flag := range []string{"no-prompt", "tui", "interactive", "edit"} {
+		if c.Bool(flag) {
+			modes = append(modes, "--" + flag)
+		}
+	}
+	if len(modes) > 1 {
+		return cli.NewExitError(fmt.Sprintf("%s can't be used together", strings.Join(modes, " and ")), 1)
+	}
+
+	result := selectPaths(c)
+	delPaths := result.Selected
 	sourceDir := c.Args()[1]
 	destDir := c.Args()[2]
 
@@ -205,41 +574,61 @@ func move(c *cli.Context) (err error) {
 	var selectedPaths paths.FilePaths
 	if c.Bool("no-prompt") {
 		selectedPaths = delPaths
+	} else if c.Bool("tui") {
+		// The selection is confirmed in the interface.
+		selectedPaths, moveFiles, err = runTUI(delPaths, result.MaxSize)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
 	} else {
-		// Print all file paths.
-		printPaths(os.Stdout, delPaths)
-
-		// Prompt the user to choose the file to transfer.
-		var selectedNumbers []int
-		for {
-			fmt.Println("\nSelect which files to transfer. You can specify comma-separated ranges of numbers (e.g. '1-9,15,17-20'). Leave blank to select all files.")
-			fmt.Print("> ")
-			numberRanges := readInput()
-			selectedNumbers, err = parse.ReadNumberRanges(numberRanges)
-			if err == nil {
-				break
+		if c.Bool("interactive") {
+			// Ask about each file in turn.
+			selectedPaths, err = reviewPaths(stdinReader, os.Stdout, delPaths)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if len(selectedPaths) == 0 {
+				fmt.Println("\n0 files moved")
+				return nil
+			}
+		} else if c.Bool("edit") {
+			// Keep the files whose lines the user didn't remove.
+			selectedPaths, err = editPaths(delPaths)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if len(selectedPaths) == 0 {
+				fmt.Println("0 files moved")
+				return nil
 			}
-		}
-		if len(selectedNumbers) == 0 {
-			selectedPaths = delPaths
 		} else {
-			for _, num := range selectedNumbers {
-				selectedPaths = append(selectedPaths, delPaths[num - 1])
+			// Print all file paths.
+			printPaths(os.Stdout, delPaths, terminalQuoter())
+
+			// Prompt the user to choose the file to transfer.
+			var selectedNumbers []int
+			for {
+				fmt.Println("\nSelect which files to transfer. You can specify comma-separated ranges of numbers (e.g. '1-9,15,17-20'). Leave blank to select all files.")
+				fmt.Print("> ")
+				numberRanges := readInput()
+				selectedNumbers, err = parse.ReadNumberRanges(numberRanges)
+				if err == nil {
+					break
+				}
+			}
+			if len(selectedNumbers) == 0 {
+				selectedPaths = delPaths
+			} else {
+				for _, num := range selectedNumbers {
+					selectedPaths = append(selectedPaths, delPaths[num - 1])
+				}
 			}
 		}
 
 		// Prompt the user to confirm the file transfer.
 		fmt.Println()
-		printPaths(os.Stdout, selectedPaths)
-		fmt.Printf("\nMove these %d files? [y/N] ", len(selectedPaths))
-		confirmation := readInput()
-		confirmation = strings.ToLower(confirmation)
-		confirmation = strings.TrimSuffix(confirmation, "\n")
-		switch confirmation {
-		case "y", "yes":
-		default:
-			moveFiles = false
-		}
+		printPaths(os.Stdout, selectedPaths, terminalQuoter())
+		moveFiles = promptConfirmation(fmt.Sprintf("\nMove these %d files?", len(selectedPaths)))
 	}
 
 	if moveFiles {
@@ -256,25 +645,43 @@ func move(c *cli.Context) (err error) {
 	return nil
 }
 
-// getPaths returns the paths of files that should be cleaned up based on the
-// given arguments.
-func getPaths(c *cli.Context) (delPaths paths.FilePaths) {
-	// Parse arguments.
-	maxSize, err := parse.ReadFileSize(c.Args()[0])
-	if err != nil {
-		log.Fatal(err)
-	}
-	startDir := c.Args()[1]
-	minDuration, err := parse.ReadDuration(c.GlobalString("min-time"))
-	if err != nil {
-		log.Fatal(err)
-	}
+// selection holds the file paths that were found at each step of selecting
+// files to be cleaned up.
+type selection struct {
+	StartDir string
+	MaxSize int64
+	MinDuration time.Duration
+	AllPaths paths.FilePaths
+	Exclude *paths.Exclude
+	Pins *paths.Pins
+	Protected paths.FilePaths
+	Where *paths.Expression
+	References []string
+	BackedUpGroups []paths.FilePaths
+	FindDuplicates bool
+	DuplicateDirGroups []paths.FilePaths
+	DuplicateGroups []paths.FilePaths
+	FindSimilar bool
+	SimilarGroups []paths.FilePaths
+	DuplicatePaths paths.FilePaths
+	Quotas paths.Quotas
+	QuotaPaths paths.FilePaths
+	OtherPaths paths.FilePaths
+	Selected paths.FilePaths
+}
+
+// scanPaths finds all the files in startDir and returns the results. It also
+// returns the files which are not excluded or pinned based on the given
+// arguments.
+func scanPaths(c *cli.Context, startDir string) (result *selection, nonExcludedPaths paths.FilePaths) {
+	result = &selection{StartDir: startDir}
 
 	// Find all paths in the directory.
 	allPaths, err := paths.ScanTree(startDir, paths.ModeFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	result.AllPaths = allPaths
 
 	// Parse the exclude patterns or the exclude pattern file if given.
 	var exclude *paths.Exclude
@@ -289,67 +696,415 @@ func getPaths(c *cli.Context) (delPaths paths.FilePaths) {
 	for _, pattern := range c.GlobalStringSlice("exclude") {
 		exclude.Patterns = append(exclude.Patterns, pattern)
 	}
+	result.Exclude = exclude
+
+	pins, err := paths.NewPins(c.GlobalString("pin-database"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	result.Pins = pins
+
+	// Parse the filter expression if given.
+	if c.GlobalString("where") != "" {
+		result.Where, err = paths.ParseExpression(c.GlobalString("where"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// Ignore paths that match exclude patterns.
-	var nonExcludedPaths paths.FilePaths
+	// Ignore paths that match exclude patterns, are pinned or are in a
+	// reference tree.
+	result.References = c.GlobalStringSlice("reference")
+	refDirs := paths.ResolveReferences(result.References)
 	for _, filePath := range allPaths {
-		if !exclude.CheckMatch(filePath.Path, startDir) {
-			nonExcludedPaths = append(nonExcludedPaths, filePath)
+		if paths.InReferences(filePath.Path, refDirs) {
+			continue
+		}
+		if exclude.CheckMatch(filePath.Path, startDir) || pins.CheckMatch(filePath.Path) {
+			result.Protected = append(result.Protected, filePath)
+			continue
 		}
+		nonExcludedPaths = append(nonExcludedPaths, filePath)
+	}
+
+	return result, nonExcludedPaths
+}
+
+// newDuplicateFinder returns a DuplicateFinder based on the given arguments.
+func newDuplicateFinder(c *cli.Context) *paths.DuplicateFinder {
+	algorithm, err := paths.ParseHashAlgorithm(c.GlobalString("hash"))
+	if err != nil {
+		log.Fatal(err)
 	}
 
+	finder := paths.NewDuplicateFinder()
+	finder.Workers = c.GlobalInt("hash-workers")
+	finder.IOLimit = c.GlobalInt("io-limit")
+	finder.Algorithm = algorithm
+	finder.VerifyBytes = c.GlobalBool("verify-bytes")
+	finder.CacheHashes = c.GlobalBool("cache-hashes")
+	return finder
+}
+
+// getKeepRules returns the rules for choosing which copy of a duplicate file to
+// keep based on the given arguments.
+func getKeepRules(c *cli.Context) []paths.KeepRule {
+	specs := c.GlobalStringSlice("keep")
+	if len(specs) == 0 {
+		return []paths.KeepRule{paths.KeepNewest}
+	}
+
+	rules := make([]paths.KeepRule, 0, len(specs))
+	for _, spec := range specs {
+		rule, err := paths.ParseKeepRule(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// findDuplicateGroups finds groups of files among candidates which are backed
+// up in a reference tree, groups of redundant directories and groups of
+// duplicate files based on the given arguments. Excluded and pinned files are
+// compared too so that their copies can be cleaned up. The copy in the
+// reference tree comes first in each group of backed up files. Files which are
+// backed up or in a redundant directory are left out of the groups of
+// duplicate files. Duplicates within candidates are only found if
+// result.FindDuplicates is set.
+func findDuplicateGroups(c *cli.Context, result *selection, candidates paths.FilePaths) (backedUpGroups, dirGroups, groups []paths.FilePaths) {
+	refPaths, err := paths.ScanReferences(result.References)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hashPaths := make(paths.FilePaths, 0, len(candidates) + len(result.Protected) + len(refPaths))
+	hashPaths = append(hashPaths, candidates...)
+	hashPaths = append(hashPaths, result.Protected...)
+	hashPaths = append(hashPaths, refPaths...)
+	backedUpGroups, groups = paths.FindBackedUp(newDuplicateFinder(c).Find(hashPaths), refPaths)
+	backedUpGroups = removeProtected(backedUpGroups, result.Protected)
+	if !result.FindDuplicates {
+		return backedUpGroups, nil, nil
+	}
+	if c.GlobalBool("no-duplicate-dirs") {
+		return backedUpGroups, nil, groups
+	}
+
+	dirGroups = paths.FindDuplicateDirs(
+		groups, result.AllPaths, candidates, result.StartDir,
+		getKeepRules(c))
+	return backedUpGroups, dirGroups, paths.RemoveDirContents(groups, dirGroups)
+}
+
+// getDuplicateKeepRules returns the rules for choosing which copy of a
+// duplicate file to keep. Copies which are excluded or pinned are always
+// preferred, and the other rules are based on the given arguments.
+func getDuplicateKeepRules(c *cli.Context, result *selection) []paths.KeepRule {
+	return append(
+		[]paths.KeepRule{paths.KeepProtected(result.Protected)},
+		getKeepRules(c)...)
+}
+
+// removeProtected returns a copy of groups without the files in protected,
+// except for the first file in each group, which is kept. Groups which are left
+// with fewer than two files are omitted.
+func removeProtected(groups []paths.FilePaths, protected paths.FilePaths) (output []paths.FilePaths) {
+	for _, group := range groups {
+		otherCopies := group[1:].Difference(protected)
+		if len(otherCopies) > 0 {
+			output = append(output, append(paths.FilePaths{group[0]}, otherCopies...))
+		}
+	}
+	return output
+}
+
+// reclaimableGroups returns the groups of duplicates in result in which every
+// copy after the first can be cleaned up. Files which are excluded or pinned
+// are left out of the groups of duplicate files, which must have already been
+// sorted by KeepDuplicates.
+func reclaimableGroups(result *selection) []paths.FilePaths {
+	groups := append([]paths.FilePaths{}, result.BackedUpGroups...)
+	groups = append(groups, result.DuplicateDirGroups...)
+	return append(groups, removeProtected(result.DuplicateGroups, result.Protected)...)
+}
+
+// selectPaths selects the files that should be cleaned up based on the given
+// arguments and returns the results of each step.
+func selectPaths(c *cli.Context) *selection {
+	// Parse arguments.
+	maxSize, err := parse.ReadFileSize(c.Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return selectPathsFrom(c, c.Args()[1], maxSize)
+}
+
+// selectPathsFrom is like selectPaths, but it selects up to maxSize bytes of
+// files in startDir instead of using the arguments.
+func selectPathsFrom(c *cli.Context, startDir string, maxSize int64) *selection {
+	minDuration, err := parse.ReadDuration(c.GlobalString("min-time"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, nonExcludedPaths := scanPaths(c, startDir)
+	allPaths := result.AllPaths
+	result.MaxSize = maxSize
+	result.MinDuration = minDuration
+
 	// Find duplicate paths if applicable.
 	var duplicatePaths paths.FilePaths
-	if !c.GlobalBool("no-duplicates") {
-		duplicatePaths = paths.GetOldestDuplicates(nonExcludedPaths)
-		sort.Slice(duplicatePaths, func(i, j int) bool {
-			return duplicatePaths[j].Stat.Size() < duplicatePaths[i].Stat.Size()
-		})
+	result.FindDuplicates = !c.GlobalBool("no-duplicates")
+	if result.FindDuplicates || len(result.References) > 0 {
+		result.BackedUpGroups, result.DuplicateDirGroups, result.DuplicateGroups = findDuplicateGroups(
+			c, result, nonExcludedPaths)
+		for _, backedUpGroup := range result.BackedUpGroups {
+			duplicatePaths = append(duplicatePaths, backedUpGroup[1:]...)
+		}
+		keptDuplicates := paths.KeepDuplicates(result.DuplicateGroups, getDuplicateKeepRules(c, result))
+		duplicatePaths = append(duplicatePaths, keptDuplicates.Difference(result.Protected)...)
 		nonExcludedPaths = nonExcludedPaths.Difference(duplicatePaths)
+
+		// Redundant directories are handled as single units.
+		for _, dirGroup := range result.DuplicateDirGroups {
+			for _, dirPath := range dirGroup[1:] {
+				duplicatePaths = append(duplicatePaths, dirPath)
+				nonExcludedPaths = nonExcludedPaths.Difference(dirPath.Metadata.Contents)
+			}
+		}
+	}
+
+	// Find similar images if applicable. Images other than the one with the
+	// highest resolution in each group are treated like duplicates.
+	result.FindSimilar = c.GlobalBool("similar-images")
+	if result.FindSimilar {
+		finder := paths.NewSimilarImageFinder()
+		finder.MaxDistance = c.GlobalInt("similar-distance")
+		finder.Workers = c.GlobalInt("hash-workers")
+		result.SimilarGroups = finder.Find(nonExcludedPaths)
+		similarPaths := paths.SmallerImages(result.SimilarGroups)
+		nonExcludedPaths = nonExcludedPaths.Difference(similarPaths)
+		duplicatePaths = append(duplicatePaths, similarPaths...)
+	}
+
+	sort.SliceStable(duplicatePaths, func(i, j int) bool {
+		return duplicatePaths[j].Size() < duplicatePaths[i].Size()
+	})
+
+	// Combine companion files into single units if applicable. This is done
+	// before applying the filter expression so that every file in a unit must
+	// match it. A duplicate with companions which aren't all redundant is
+	// combined with its companions instead so that the unit isn't split up.
+	var companionSets []paths.CompanionSet
+	for _, spec := range c.GlobalStringSlice("companions") {
+		set, err := paths.ParseCompanionSet(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		companionSets = append(companionSets, set)
+	}
+	duplicatePaths, withCompanions := paths.SeparateCompanions(duplicatePaths, allPaths, companionSets)
+	nonExcludedPaths = append(nonExcludedPaths, withCompanions...)
+	nonExcludedPaths = paths.GroupCompanions(nonExcludedPaths, companionSets, result.Protected)
+
+	// Ignore paths that don't match the filter expression if given. This is
+	// done after finding duplicates so that the expression can check whether
+	// each file is a duplicate.
+	if result.Where != nil {
+		duplicatePaths = result.Where.Filter(duplicatePaths, startDir)
+		nonExcludedPaths = result.Where.Filter(nonExcludedPaths, startDir)
 	}
+	result.DuplicatePaths = duplicatePaths
 
-	// Select non-duplicate paths to be cleaned up.
-	delPaths = append(duplicatePaths, paths.Filter(nonExcludedPaths, maxSize, minDuration)...)
+	// Parse the quota file if given.
+	var quotas paths.Quotas
+	if c.GlobalString("quota-file") != "" {
+		quotas, err = paths.NewQuotasFromFile(c.GlobalString("quota-file"), startDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		quotas = quotas.Measure(allPaths)
+	}
+	result.Quotas = quotas
+
+	// Select non-duplicate paths to be cleaned up. Paths in a subtree with a
+	// quota are selected until the subtree is under its quota instead of
+	// counting toward the total size.
+	var quotaPaths, otherPaths paths.FilePaths
+	for _, filePath := range nonExcludedPaths {
+		if quotas.Match(filePath.Path) < 0 {
+			otherPaths = append(otherPaths, filePath)
+		} else {
+			quotaPaths = append(quotaPaths, filePath)
+		}
+	}
+	result.QuotaPaths = quotaPaths
+	result.OtherPaths = otherPaths
+	delPaths := append(duplicatePaths, paths.Filter(otherPaths, maxSize, minDuration)...)
+	delPaths = append(delPaths, paths.FilterQuotas(quotaPaths, quotas.Reclaim(duplicatePaths), minDuration)...)
 
 	// Assign a piece of metadata to each file path so that they can retain
 	// their original rank even if the returned slice is modified.
 	for i := range delPaths {
 		delPaths[i].Metadata.Rank = i + 1
 	}
+	result.Selected = delPaths
 
-	return delPaths
+	return result
 }
 
 // printPaths prints a formatted table of information about each FilePath in
 // pathsToPrint to output. This includes the file's rank, path, size, last
 // access time and whether the file is a duplicate.
-func printPaths(output io.Writer, pathsToPrint paths.FilePaths) {
+func printPaths(output io.Writer, pathsToPrint paths.FilePaths, quote func(string) string) {
 	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
 	fmt.Fprintln(writer, "#\tSize\tLast Access\tDuplicate\tPath")
 
 	for _, filePath := range pathsToPrint {
-		var isDuplicate string
-		if filePath.Metadata.Duplicate {
-			isDuplicate = "Yes"
-		} else {
-			isDuplicate = "No"
-		}
-
 		fmt.Fprintf(
 			writer, "%d\t%s\t%v\t%s\t%s\n",
 			filePath.Metadata.Rank,
-			parse.FormatFileSize(filePath.Stat.Size()),
-			filePath.Time.AccessTime().Format("Jan 01 2006 15:04"),
-			isDuplicate,
-			filePath.Path)
+			parse.FormatFileSize(filePath.Size()),
+			filePath.Time.AccessTime().Format(timeFormat),
+			duplicateLabel(filePath),
+			displayPath(filePath, quote))
+	}
+	writer.Flush()
+}
+
+// duplicateLabel returns whether filePath is a duplicate, a backed up file or
+// a similar image as it is shown in the Duplicate column.
+func duplicateLabel(filePath paths.FilePath) string {
+	switch {
+	case filePath.Metadata.BackedUp:
+		return "Backed up"
+	case filePath.Metadata.Duplicate:
+		return "Yes"
+	case filePath.Metadata.Similar:
+		return "Similar"
+	default:
+		return "No"
+	}
+}
+
+// displayPath returns the path of filePath as it should be shown to the user.
+// Directories which are handled as single units end with a path separator, and
+// files with companions are followed by the extensions of their companions.
+// The path is quoted using quote.
+func displayPath(filePath paths.FilePath, quote func(string) string) string {
+	if filePath.Metadata.Contents == nil {
+		return quote(filePath.Path)
+	}
+	if filePath.Stat.IsDir() {
+		return quote(filePath.Path + string(filepath.Separator))
+	}
+
+	var exts []string
+	for _, companion := range filePath.Metadata.Contents {
+		if companion.Path != filePath.Path {
+			exts = append(exts, quote(filepath.Ext(companion.Path)))
+		}
+	}
+	return fmt.Sprintf("%s (+%s)", quote(filePath.Path), strings.Join(exts, ", "))
+}
+
+// hasDuplicates returns true if any of the given file paths is a duplicate, a
+// backed up file or a similar image.
+func hasDuplicates(filePaths paths.FilePaths) bool {
+	for _, filePath := range filePaths {
+		if filePath.Metadata.Duplicate || filePath.Metadata.BackedUp || filePath.Metadata.Similar {
+			return true
+		}
+	}
+	return false
+}
+
+// printKeptPaths prints a formatted table of the copy of each duplicate,
+// backed up file or similar image in pathsToPrint that is kept to output.
+// Other files are skipped.
+func printKeptPaths(output io.Writer, pathsToPrint paths.FilePaths, quote func(string) string) {
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, "#\tKept Copy")
+
+	for _, filePath := range pathsToPrint {
+		if filePath.Metadata.Duplicate || filePath.Metadata.BackedUp || filePath.Metadata.Similar {
+			kept := filePath.Metadata.Kept
+			if filePath.Stat.IsDir() {
+				kept += string(filepath.Separator)
+			}
+			fmt.Fprintf(writer, "%d\t%s\n", filePath.Metadata.Rank, quote(kept))
+		}
+	}
+	writer.Flush()
+}
+
+// printQuotas prints a formatted table of the size of each subtree in quotas
+// before and after the files in delPaths are cleaned up to output.
+func printQuotas(output io.Writer, quotas paths.Quotas, delPaths paths.FilePaths, quote func(string) string) {
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, "Quota\tBefore\tAfter\tSubtree")
+
+	remaining := quotas.Reclaim(delPaths)
+	for i, quota := range quotas {
+		fmt.Fprintf(
+			writer, "%s\t%s\t%s\t%s\n",
+			parse.FormatFileSize(quota.MaxSize),
+			parse.FormatFileSize(quota.UsedSize),
+			parse.FormatFileSize(remaining[i].UsedSize),
+			quote(quota.Path))
+	}
+	writer.Flush()
+}
+
+// printDirTotals prints a formatted table of the totals in dirTotals to
+// output. The first directory is the one that the others are in, and each
+// directory is indented by its depth below it. The share of each directory is
+// its fraction of totalSize.
+func printDirTotals(output io.Writer, dirTotals []paths.DirTotal, totalSize int64, quote func(string) string) {
+	writer := tabwriter.NewWriter(output, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(writer, "Size\tFiles\tShare\tDirectory")
+
+	for i, dirTotal := range dirTotals {
+		name := filepath.Base(dirTotal.Path)
+		if i == 0 {
+			name = dirTotal.Path
+		}
+		share := 0.0
+		if totalSize > 0 {
+			share = float64(dirTotal.Size) / float64(totalSize) * 100
+		}
+		fmt.Fprintf(
+			writer, "%s\t%d\t%.1f%%\t%s%s\n",
+			parse.FormatFileSize(dirTotal.Size),
+			dirTotal.Files,
+			share,
+			strings.Repeat("  ", dirTotal.Depth),
+			quote(name + string(filepath.Separator)))
 	}
 	writer.Flush()
 }
 
+// promptConfirmation prints message and prompts the user to answer yes or no.
+// It returns true if the user answered yes.
+func promptConfirmation(message string) bool {
+	fmt.Printf("%s [y/N] ", message)
+	confirmation := readInput()
+	confirmation = strings.ToLower(confirmation)
+	confirmation = strings.TrimSuffix(confirmation, "\n")
+	switch confirmation {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
 // readInput reads a line from stdin.
 func readInput() string {
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+	input, err := stdinReader.ReadString('\n')
 	if err != nil {
 		log.Fatal(err)
 	}
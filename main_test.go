@@ -0,0 +1,71 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lostatc/reddup/paths"
+)
+
+// setupTestFiles creates a temporary directory with a file at each of the
+// relative paths in names and returns those files in the same order, ranked
+// from 1. It also returns a function which removes the directory.
+func setupTestFiles(t *testing.T, names ...string) (paths.FilePaths, func()) {
+	tempPath, err := ioutil.TempDir("", "reddup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	teardownFunc := func() { os.RemoveAll(tempPath) }
+
+	var files paths.FilePaths
+	for i, name := range names {
+		path := filepath.Join(tempPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			teardownFunc()
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0600); err != nil {
+			teardownFunc()
+			t.Fatal(err)
+		}
+		filePath, err := paths.NewFilePath(path)
+		if err != nil {
+			teardownFunc()
+			t.Fatal(err)
+		}
+		filePath.Metadata.Rank = i + 1
+		files = append(files, *filePath)
+	}
+
+	return files, teardownFunc
+}
+
+// assertError checks whether an error was returned when one was expected.
+func assertError(t *testing.T, err error, expected bool) {
+	if expected && err == nil {
+		t.Error("expected an error")
+	} else if !expected && err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
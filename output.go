@@ -0,0 +1,351 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// jsonSchemaVersion is the version of the JSON output. It is only incremented
+// when a field is removed or its meaning changes, not when a field is added.
+const jsonSchemaVersion = 1
+
+// jsonFile is the JSON representation of a file that should be cleaned up.
+type jsonFile struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	RelPath string `json:"rel_path"`
+	Size int64 `json:"size"`
+	AllocatedSize int64 `json:"allocated_size"`
+	Atime time.Time `json:"atime"`
+	Mtime time.Time `json:"mtime"`
+	Ctime *time.Time `json:"ctime"`
+	Btime *time.Time `json:"btime"`
+	Rank int `json:"rank"`
+	Score *float64 `json:"score"`
+	Reason string `json:"reason"`
+	DuplicateGroup *int `json:"duplicate_group"`
+	Kept *string `json:"kept"`
+	Contents []string `json:"contents"`
+}
+
+// jsonSummary is the JSON representation of the totals for all the files that
+// should be cleaned up.
+type jsonSummary struct {
+	Type string `json:"type"`
+	SchemaVersion int `json:"schema_version"`
+	Source string `json:"source"`
+	MaxSize int64 `json:"max_size"`
+	Files int `json:"files"`
+	TotalSize int64 `json:"total_size"`
+	TotalAllocatedSize int64 `json:"total_allocated_size"`
+	DuplicateSize int64 `json:"duplicate_size"`
+	DuplicateGroups int `json:"duplicate_groups"`
+}
+
+// jsonOutput is the JSON representation of the output of the 'list' command.
+type jsonOutput struct {
+	SchemaVersion int `json:"schema_version"`
+	Summary jsonSummary `json:"summary"`
+	Files []jsonFile `json:"files"`
+}
+
+// templateFuncs are the functions available in output templates.
+var templateFuncs = template.FuncMap {
+	"size": parse.FormatFileSize,
+	"age": func(t time.Time) string {
+		return parse.FormatDuration(time.Since(t))
+	},
+	"shquote": parse.QuoteShell,
+}
+
+// quoteStyles are the functions for quoting paths for each value of --quote.
+var quoteStyles = map[string]func(string) string {
+	"shell": parse.QuoteShell,
+	"c": parse.QuoteC,
+	"none": func(path string) string { return path },
+}
+
+// getQuoter returns the function for quoting paths in the given style. If
+// style is empty, paths are quoted like terminalQuoter unless they are
+// NUL-terminated.
+func getQuoter(style string, nullTerminated bool) (func(string) string, error) {
+	if style == "" {
+		if nullTerminated {
+			return quoteStyles["none"], nil
+		}
+		return terminalQuoter(), nil
+	}
+	quote, ok := quoteStyles[style]
+	if !ok {
+		return nil, fmt.Errorf("unknown quoting style '%s'", style)
+	}
+	return quote, nil
+}
+
+// terminalQuoter returns a function which escapes paths with C escape
+// sequences if stdout is a terminal and leaves them unchanged otherwise.
+func terminalQuoter() func(string) string {
+	info, err := os.Stdout.Stat()
+	if err == nil && info.Mode() & os.ModeCharDevice != 0 {
+		return quoteStyles["c"]
+	}
+	return quoteStyles["none"]
+}
+
+// templateFile is the data passed to an output template for each file. It
+// has every field and method of the file as well as some computed fields.
+type templateFile struct {
+	paths.FilePath
+	RelPath string
+	HumanSize string
+	Age string
+	Rank int
+	Reason string
+	DuplicateGroup int
+	Kept string
+}
+
+// templateFormatPrefix marks a value of --format as a template for printing
+// each file.
+const templateFormatPrefix = "template:"
+
+// outputTemplates are the templates for printing each file and for printing
+// text before and after the list.
+type outputTemplates struct {
+	File *template.Template
+	Header *template.Template
+	Footer *template.Template
+}
+
+// newOutputTemplates parses the text of the templates for printing each
+// file and for printing text before and after the list. header and footer may
+// be empty.
+func newOutputTemplates(file, header, footer string) (templates outputTemplates, err error) {
+	parseTemplate := func(name, text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		parsed, err := template.New(name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %v", name, err)
+		}
+		return parsed, nil
+	}
+
+	if templates.File, err = parseTemplate("format", file); err != nil {
+		return templates, err
+	}
+	if templates.Header, err = parseTemplate("header", header); err != nil {
+		return templates, err
+	}
+	if templates.Footer, err = parseTemplate("footer", footer); err != nil {
+		return templates, err
+	}
+
+	return templates, nil
+}
+
+// duplicateGroupIDs returns a map of the paths of files and directories in
+// each group of duplicates in result to the 1-based ID of their group.
+func duplicateGroupIDs(result *selection) map[string]int {
+	ids := make(map[string]int)
+	var allGroups []paths.FilePaths
+	allGroups = append(allGroups, result.BackedUpGroups...)
+	allGroups = append(allGroups, result.DuplicateDirGroups...)
+	allGroups = append(allGroups, result.DuplicateGroups...)
+	allGroups = append(allGroups, result.SimilarGroups...)
+
+	for i, group := range allGroups {
+		for _, filePath := range group {
+			ids[filePath.Path] = i + 1
+		}
+	}
+
+	return ids
+}
+
+// selectionReason returns why filePath was selected to be cleaned up.
+func selectionReason(filePath paths.FilePath, quotas paths.Quotas) string {
+	switch {
+	case filePath.Metadata.BackedUp:
+		return "backed-up"
+	case filePath.Metadata.Duplicate:
+		return "duplicate"
+	case filePath.Metadata.Similar:
+		return "similar"
+	case quotas.Match(filePath.Path) >= 0:
+		return "quota"
+	default:
+		return "budget"
+	}
+}
+
+// newJSONFile returns the JSON representation of filePath, which was selected
+// in result. groupIDs is the map returned by duplicateGroupIDs.
+func newJSONFile(filePath paths.FilePath, result *selection, groupIDs map[string]int) jsonFile {
+	record := jsonFile {
+		Type: "file",
+		Path: filePath.Path,
+		Size: filePath.Size(),
+		AllocatedSize: filePath.AllocatedSize(),
+		Atime: filePath.Time.AccessTime(),
+		Mtime: filePath.Time.ModTime(),
+		Rank: filePath.Metadata.Rank,
+		Reason: selectionReason(filePath, result.Quotas),
+	}
+
+	if relPath, err := filepath.Rel(result.StartDir, filePath.Path); err == nil {
+		record.RelPath = relPath
+	} else {
+		record.RelPath = filePath.Path
+	}
+	if filePath.Time.HasChangeTime() {
+		ctime := filePath.Time.ChangeTime()
+		record.Ctime = &ctime
+	}
+	if filePath.Time.HasBirthTime() {
+		btime := filePath.Time.BirthTime()
+		record.Btime = &btime
+	}
+	if score := paths.Priority(filePath); !math.IsInf(score, 0) {
+		record.Score = &score
+	}
+	if id, ok := groupIDs[filePath.Path]; ok {
+		record.DuplicateGroup = &id
+	}
+	if filePath.Metadata.Kept != "" {
+		kept := filePath.Metadata.Kept
+		record.Kept = &kept
+	}
+	for _, contentPath := range filePath.Metadata.Contents {
+		record.Contents = append(record.Contents, contentPath.Path)
+	}
+
+	return record
+}
+
+// newJSONSummary returns the totals for the files selected in result.
+func newJSONSummary(result *selection) jsonSummary {
+	summary := jsonSummary {
+		Type: "summary",
+		SchemaVersion: jsonSchemaVersion,
+		Source: result.StartDir,
+		MaxSize: result.MaxSize,
+		Files: len(result.Selected),
+	}
+
+	groups := make(map[int]bool)
+	groupIDs := duplicateGroupIDs(result)
+	for _, filePath := range result.Selected {
+		summary.TotalSize += filePath.Size()
+		summary.TotalAllocatedSize += filePath.AllocatedSize()
+		if filePath.Metadata.Duplicate || filePath.Metadata.BackedUp || filePath.Metadata.Similar {
+			summary.DuplicateSize += filePath.Size()
+			groups[groupIDs[filePath.Path]] = true
+		}
+	}
+	summary.DuplicateGroups = len(groups)
+
+	return summary
+}
+
+// printJSON prints the files selected in result to output as a single JSON
+// object.
+func printJSON(output io.Writer, result *selection) error {
+	groupIDs := duplicateGroupIDs(result)
+	records := make([]jsonFile, 0, len(result.Selected))
+	for _, filePath := range result.Selected {
+		records = append(records, newJSONFile(filePath, result, groupIDs))
+	}
+
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonOutput {
+		SchemaVersion: jsonSchemaVersion,
+		Summary: newJSONSummary(result),
+		Files: records,
+	})
+}
+
+// printNDJSON prints the files selected in result to output as one JSON object
+// per line followed by a line with the summary.
+func printNDJSON(output io.Writer, result *selection) error {
+	groupIDs := duplicateGroupIDs(result)
+	encoder := json.NewEncoder(output)
+	for _, filePath := range result.Selected {
+		if err := encoder.Encode(newJSONFile(filePath, result, groupIDs)); err != nil {
+			return err
+		}
+	}
+	return encoder.Encode(newJSONSummary(result))
+}
+
+// printTemplate prints the files selected in result to output using
+// templates. Each file is followed by terminator, and the header and footer
+// are followed by a newline if they are given. The header and footer are
+// passed the same summary as the JSON output.
+func printTemplate(output io.Writer, result *selection, templates outputTemplates, terminator string) error {
+	summary := newJSONSummary(result)
+	printSection := func(section *template.Template, data interface{}, end string) error {
+		if section == nil {
+			return nil
+		}
+		if err := section.Execute(output, data); err != nil {
+			return err
+		}
+		_, err := io.WriteString(output, end)
+		return err
+	}
+
+	if err := printSection(templates.Header, summary, "\n"); err != nil {
+		return err
+	}
+
+	groupIDs := duplicateGroupIDs(result)
+	for _, filePath := range result.Selected {
+		record := newJSONFile(filePath, result, groupIDs)
+		data := templateFile {
+			FilePath: filePath,
+			RelPath: record.RelPath,
+			HumanSize: parse.FormatFileSize(record.Size),
+			Age: parse.FormatDuration(time.Since(record.Atime)),
+			Rank: record.Rank,
+			Reason: record.Reason,
+			DuplicateGroup: groupIDs[filePath.Path],
+			Kept: filePath.Metadata.Kept,
+		}
+		if err := printSection(templates.File, data, terminator); err != nil {
+			return err
+		}
+	}
+
+	return printSection(templates.Footer, summary, "\n")
+}
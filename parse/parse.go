@@ -30,6 +30,8 @@ import (
 	"strings"
 	"fmt"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const sizePattern string = `^(?i)([0-9]+)\s*([KMGTPEZY])(B|iB)?$`
@@ -52,6 +54,20 @@ var durationReadUnits = map[string]time.Duration{"h": durationHour, "d": duratio
 var sizeFormatUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB", "ZiB", "YiB"}
 const sizeFormatBase = 1024
 
+// These are used by FormatDuration. They must be sorted from largest to
+// smallest.
+var durationFormatUnits = []string{"y", "m", "d", "h"}
+
+// This is used by QuoteShell.
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// This is used by escapeString. The keys are the characters which have their
+// own escape sequence.
+var escapeSequences = map[rune]string{
+	'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`,
+	'\\': `\\`,
+}
+
 // These are used by ReadNumberRanges.
 const rangeSeparator = ","
 const rangeSpecifier = "-"
@@ -134,6 +150,82 @@ func ReadDuration(duration string) (time.Duration, error) {
 	return output, nil
 }
 
+// FormatDuration formats a duration using the largest unit recognized by
+// ReadDuration that fits into it, rounding down (e.g. "3d"). Durations shorter
+// than an hour are formatted as "0h".
+func FormatDuration(duration time.Duration) string {
+	for _, unit := range durationFormatUnits {
+		if duration >= durationReadUnits[unit] {
+			return fmt.Sprintf("%d%s", duration / durationReadUnits[unit], unit)
+		}
+	}
+	return "0h"
+}
+
+// QuoteShell quotes a string so that a POSIX shell reads it as a single word.
+// Strings which don't contain any special characters are returned unchanged.
+// Strings which contain control characters or invalid UTF-8 are quoted as
+// $'...' with escape sequences so that they are safe to print to a terminal.
+func QuoteShell(input string) string {
+	if shellSafePattern.MatchString(input) {
+		return input
+	}
+	if needsEscape(input) {
+		return "$'" + escapeString(input, '\'') + "'"
+	}
+	return "'" + strings.Replace(input, "'", `'\''`, -1) + "'"
+}
+
+// QuoteC quotes a string using C escape sequences if it contains control
+// characters, invalid UTF-8, backslashes or double quotes. Other strings are
+// returned unchanged.
+func QuoteC(input string) string {
+	if !needsEscape(input) && !strings.ContainsAny(input, `\"`) {
+		return input
+	}
+	return `"` + escapeString(input, '"') + `"`
+}
+
+// needsEscape returns true if input contains control characters or invalid
+// UTF-8.
+func needsEscape(input string) bool {
+	if !utf8.ValidString(input) {
+		return true
+	}
+	for _, char := range input {
+		if unicode.IsControl(char) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeString replaces control characters, invalid UTF-8, backslashes and the
+// quote character in input with C escape sequences.
+func escapeString(input string, quote rune) string {
+	var builder strings.Builder
+	for i := 0; i < len(input); {
+		char, width := utf8.DecodeRuneInString(input[i:])
+		switch sequence, ok := escapeSequences[char]; {
+		case char == utf8.RuneError && width == 1:
+			fmt.Fprintf(&builder, `\x%02x`, input[i])
+		case ok:
+			builder.WriteString(sequence)
+		case char == quote:
+			builder.WriteRune('\\')
+			builder.WriteRune(char)
+		case unicode.IsControl(char) && char < utf8.RuneSelf:
+			fmt.Fprintf(&builder, `\x%02x`, char)
+		case unicode.IsControl(char):
+			fmt.Fprintf(&builder, `\u%04x`, char)
+		default:
+			builder.WriteRune(char)
+		}
+		i += width
+	}
+	return builder.String()
+}
+
 // ReadNumberRanges parses a comma separated list of number ranges (e.g. "1,7-12,15,47-50").
 func ReadNumberRanges(input string) (numbers []int, err error) {
 	if strings.TrimSpace(input) == "" {
@@ -101,6 +101,67 @@ func TestReadDuration(t *testing.T) {
 	}
 }
 
+func TestFormatDuration(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Input time.Duration
+		ExpectedOutput string
+	}{
+		{"Years", time.Hour * 10707, "1y"},
+		{"Days", time.Hour * 80, "3d"},
+		{"Hours", time.Hour * 5, "5h"},
+		{"Less than an hour", time.Minute * 30, "0h"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			assertValue(t, FormatDuration(tc.Input), tc.ExpectedOutput)
+		})
+	}
+}
+
+func TestQuoteShell(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Input string
+		ExpectedOutput string
+	}{
+		{"Safe", "dir/file-1.txt", "dir/file-1.txt"},
+		{"Spaces", "my file", "'my file'"},
+		{"Single quotes", "it's", `'it'\''s'`},
+		{"Empty", "", "''"},
+		{"Control characters", "a\nb's", `$'a\nb\'s'`},
+		{"Invalid UTF-8", "a\xffb", `$'a\xffb'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			assertValue(t, QuoteShell(tc.Input), tc.ExpectedOutput)
+		})
+	}
+}
+
+func TestQuoteC(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Input string
+		ExpectedOutput string
+	}{
+		{"Safe", "my file's.txt", "my file's.txt"},
+		{"Control characters", "a\tb\x1b", `"a\tb\x1b"`},
+		{"Quotes", `say "hi"`, `"say \"hi\""`},
+		{"Backslashes", `a\b`, `"a\\b"`},
+		{"Invalid UTF-8", "\xc3(", `"\xc3("`},
+		{"Unicode", "caf\u00e9\u0085", "\"caf\u00e9\\u0085\""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			assertValue(t, QuoteC(tc.Input), tc.ExpectedOutput)
+		})
+	}
+}
+
 func TestReadNumberRanges(t *testing.T) {
 	testCases := []struct {
 		TestName string
@@ -0,0 +1,175 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CompanionSet is a set of file extensions without the leading dot. Files in
+// the same directory with the same name apart from an extension in the same
+// set are companions, like a raw photo and its sidecar file or a movie and its
+// subtitles.
+type CompanionSet []string
+
+// ParseCompanionSet parses a comma-separated list of file extensions like
+// "cr2,jpg,xmp". Extensions are compared without regard to case.
+func ParseCompanionSet(spec string) (CompanionSet, error) {
+	var set CompanionSet
+	for _, ext := range strings.Split(spec, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" {
+			return nil, fmt.Errorf("the set of extensions '%s' contains an empty extension", spec)
+		}
+		set = append(set, ext)
+	}
+	if len(set) < 2 {
+		return nil, fmt.Errorf("the set of extensions '%s' must contain at least two extensions", spec)
+	}
+
+	return set, nil
+}
+
+// companionKey identifies the files which are companions of each other.
+type companionKey struct {
+	Dir string
+	Base string
+	Set int
+}
+
+// GroupCompanions returns paths with companion files combined into single
+// units based on sets. The largest file in each unit is its main file. The
+// unit has the path and stat of the main file, the times of the file in the
+// unit which was accessed most recently and its Metadata.Contents field set to
+// every file in the unit. Units which have a file in protected are omitted
+// entirely so that they are never split up. Files without companions are
+// returned unchanged.
+func GroupCompanions(paths FilePaths, sets []CompanionSet, protected FilePaths) FilePaths {
+	if len(sets) == 0 {
+		return paths
+	}
+
+	// Find the companions of each file.
+	units := make(map[companionKey]FilePaths)
+	var keys []companionKey
+	var output FilePaths
+	for _, path := range paths {
+		key, ok := findCompanionKey(path.Path, sets)
+		if !ok {
+			output = append(output, path)
+			continue
+		}
+		if _, ok := units[key]; !ok {
+			keys = append(keys, key)
+		}
+		units[key] = append(units[key], path)
+	}
+
+	// Find the units with a file that can't be cleaned up.
+	protectedKeys := make(map[companionKey]bool)
+	for _, path := range protected {
+		if key, ok := findCompanionKey(path.Path, sets); ok {
+			protectedKeys[key] = true
+		}
+	}
+
+	for _, key := range keys {
+		members := units[key]
+		if protectedKeys[key] {
+			continue
+		}
+		if len(members) == 1 {
+			output = append(output, members[0])
+			continue
+		}
+
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].Stat.Size() != members[j].Stat.Size() {
+				return members[i].Stat.Size() > members[j].Stat.Size()
+			}
+			return members[i].Path < members[j].Path
+		})
+		unit := members[0]
+		for _, member := range members[1:] {
+			if member.Time.AccessTime().After(unit.Time.AccessTime()) {
+				unit.Time = member.Time
+			}
+		}
+		unit.Metadata.Contents = members
+		output = append(output, unit)
+	}
+
+	return output
+}
+
+// SeparateCompanions splits redundant into the files which can be cleaned up
+// on their own and the files which have a companion in allPaths that isn't
+// also in redundant, based on sets. Those files are returned separately so
+// that they can be handled together with their companions instead. Files which
+// are handled as single units are never separated.
+func SeparateCompanions(redundant FilePaths, allPaths FilePaths, sets []CompanionSet) (alone, withCompanions FilePaths) {
+	if len(sets) == 0 {
+		return redundant, nil
+	}
+
+	isRedundant := make(map[string]bool)
+	for _, path := range redundant {
+		isRedundant[path.Path] = true
+	}
+	incomplete := make(map[companionKey]bool)
+	for _, path := range allPaths {
+		if key, ok := findCompanionKey(path.Path, sets); ok && !isRedundant[path.Path] {
+			incomplete[key] = true
+		}
+	}
+
+	for _, path := range redundant {
+		key, ok := findCompanionKey(path.Path, sets)
+		if ok && path.Metadata.Contents == nil && incomplete[key] {
+			withCompanions = append(withCompanions, path)
+		} else {
+			alone = append(alone, path)
+		}
+	}
+
+	return alone, withCompanions
+}
+
+// findCompanionKey returns the key shared by the companions of the file at
+// path. If the extension of the file isn't in any of sets, it returns false.
+func findCompanionKey(path string, sets []CompanionSet) (key companionKey, ok bool) {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return key, false
+	}
+
+	for i, set := range sets {
+		for _, setExt := range set {
+			if strings.EqualFold(ext[1:], setExt) {
+				return companionKey{filepath.Dir(path), strings.TrimSuffix(filepath.Base(path), ext), i}, true
+			}
+		}
+	}
+
+	return key, false
+}
@@ -0,0 +1,124 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"testing"
+	"path/filepath"
+)
+
+func TestParseCompanionSet(t *testing.T) {
+	testCases := []struct {
+		Spec string
+		ErrorExpected bool
+	}{
+		{"cr2,xmp", false},
+		{".MKV, .srt", false},
+		{"xmp", true},
+		{"cr2,,xmp", true},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseCompanionSet(tc.Spec)
+		assertError(t, err, tc.ErrorExpected)
+	}
+}
+
+func TestGroupCompanions(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.CR2", "aaaaaa"},
+		{"letters/a.xmp", "aa"},
+		{"letters/a.txt", "a"},
+		{"letters/b.CR2", "bbbbbb"},
+		{"letters/b.xmp", "bb"},
+		{"numbers/1.xmp", "11"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allPaths, err := ScanTree(tempPath, ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseCompanionSet("cr2,xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unit for b.CR2 is omitted because b.xmp is protected.
+	protected := FilePaths{{Path: filepath.Join(tempPath, "letters/b.xmp")}}
+	candidates := allPaths.Difference(protected)
+	grouped := GroupCompanions(candidates, []CompanionSet{set}, protected)
+	expectedPaths := []string{"letters/a.CR2", "letters/a.txt", "letters/upper/A.txt", "numbers/1.txt", "numbers/1.xmp"}
+	assertPathsEqual(t, grouped, expectedPaths, tempPath)
+
+	for _, path := range grouped {
+		if path.Path != filepath.Join(tempPath, "letters/a.CR2") {
+			continue
+		}
+		assertPathsEqual(t, path.Metadata.Contents, []string{"letters/a.CR2", "letters/a.xmp"}, tempPath)
+		if path.Size() != 8 {
+			t.Errorf("size %v != 8", path.Size())
+		}
+	}
+}
+
+func TestSeparateCompanions(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.CR2", "aaaaaa"},
+		{"letters/a.xmp", "aa"},
+		{"letters/b.CR2", "bbbbbb"},
+		{"letters/b.xmp", "bb"},
+		{"numbers/1.CR2", "111111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allPaths, err := ScanTree(tempPath, ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := ParseCompanionSet("cr2,xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a.CR2 is separated because a.xmp isn't redundant. Both b.CR2 and b.xmp
+	// are redundant, and 1.CR2 has no companions.
+	redundant, err := NewFilePathsFromRel(
+		[]string{"letters/a.CR2", "letters/b.CR2", "letters/b.xmp", "numbers/1.CR2", "letters/a.txt"}, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alone, withCompanions := SeparateCompanions(*redundant, allPaths, []CompanionSet{set})
+	assertPathsEqual(
+		t, alone, []string{"letters/b.CR2", "letters/b.xmp", "numbers/1.CR2", "letters/a.txt"}, tempPath)
+	assertPathsEqual(t, withCompanions, []string{"letters/a.CR2"}, tempPath)
+}
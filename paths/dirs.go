@@ -0,0 +1,373 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var errSpecialFile = errors.New("the directory contains a file which is not a regular file")
+
+// FindDuplicateDirs returns groups of redundant directories under startDir.
+// A directory is redundant if every file in it is found with the same relative
+// path and contents in another directory, which makes it either identical to
+// or a subset of that directory. groups are the groups of identical files
+// returned by DuplicateFinder.Find, allPaths are all the files in startDir and
+// candidates are the files which may be cleaned up. Directories which contain
+// any other file are never redundant.
+//
+// The first directory in each group is the one that is kept. When several
+// directories are identical, a directory which contains files that can't be
+// cleaned up is kept if there is one. Otherwise, the one that is kept is
+// chosen according to rules like in KeepDuplicates. Every other directory has
+// its Metadata.Kept field set to the path of the directory that is kept and
+// its Metadata.Contents field set to the files in it. Only the outermost
+// redundant directories are returned, and a directory is never returned if
+// doing so would leave no copy of one of its files outside of the returned
+// directories.
+func FindDuplicateDirs(groups []FilePaths, allPaths FilePaths, candidates FilePaths, startDir string, rules []KeepRule) (dirGroups []FilePaths) {
+	root := filepath.Clean(startDir)
+
+	// Files in the same group of duplicates share an ID.
+	contentIDs := make(map[string]int)
+	for i, group := range groups {
+		for _, path := range group {
+			contentIDs[path.Path] = i
+		}
+	}
+	isCandidate := make(map[string]bool)
+	for _, path := range candidates {
+		isCandidate[path.Path] = true
+	}
+
+	// Find the files in each directory and the directories which contain a file
+	// that is unique or can't be cleaned up.
+	contents := make(map[string]FilePaths)
+	unique := make(map[string]bool)
+	protected := make(map[string]bool)
+	for _, path := range allPaths {
+		_, isDuplicate := contentIDs[path.Path]
+		for dir := path.Path; dir != root && dir != filepath.Dir(dir); {
+			dir = filepath.Dir(dir)
+			contents[dir] = append(contents[dir], path)
+			if !isDuplicate {
+				unique[dir] = true
+			} else if !isCandidate[path.Path] {
+				protected[dir] = true
+			}
+		}
+	}
+
+	// Group directories with identical contents. Directories with a file that
+	// can't be cleaned up may still be kept over identical directories.
+	identical := make(map[string][]string)
+	for dir := range contents {
+		if dir != root && !unique[dir] {
+			signature := dirSignature(dir, contents[dir], contentIDs)
+			identical[signature] = append(identical[signature], dir)
+		}
+	}
+
+	// Find the directory that is kept for each directory. A directory which is
+	// a subset of another directory defers to that directory.
+	keptDirs := make(map[string]string)
+	keptDir := func(dir string) string {
+		if kept, ok := keptDirs[dir]; ok {
+			return kept
+		}
+		return dir
+	}
+	var dirs []string
+	for _, sameDirs := range identical {
+		for _, dir := range sameDirs {
+			if !protected[dir] {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(contents[dirs[i]]) > len(contents[dirs[j]])
+	})
+	resolved := make(map[string]bool)
+	for _, dir := range dirs {
+		if resolved[dir] {
+			continue
+		}
+		sameDirs := identical[dirSignature(dir, contents[dir], contentIDs)]
+
+		superset := findSuperset(dir, contents, contentIDs, groups)
+		if superset == "" && len(sameDirs) < 2 {
+			resolved[dir] = true
+			continue
+		}
+
+		var kept string
+		if superset == "" {
+			// Prefer keeping directories which can't be cleaned up.
+			keepDirs := sameDirs
+			for _, sameDir := range sameDirs {
+				if protected[sameDir] {
+					keepDirs = nil
+					break
+				}
+			}
+			if keepDirs == nil {
+				for _, sameDir := range sameDirs {
+					if protected[sameDir] {
+						keepDirs = append(keepDirs, sameDir)
+					}
+				}
+			}
+
+			dirPaths := make(FilePaths, 0, len(keepDirs))
+			for _, keepDir := range keepDirs {
+				if dirPath, err := NewFilePath(keepDir); err == nil {
+					dirPaths = append(dirPaths, *dirPath)
+				}
+			}
+			if len(dirPaths) == 0 {
+				continue
+			}
+			sortByRules(dirPaths, rules)
+			kept = dirPaths[0].Path
+		} else {
+			// Supersets contain more files, so they have already been resolved.
+			kept = keptDir(superset)
+		}
+
+		for _, sameDir := range sameDirs {
+			if sameDir != kept && !protected[sameDir] {
+				keptDirs[sameDir] = kept
+			}
+			resolved[sameDir] = true
+		}
+	}
+
+	// Choose the outermost redundant directories first, and skip directories
+	// which would leave a file without any copies.
+	var redundant []string
+	for dir := range keptDirs {
+		redundant = append(redundant, dir)
+	}
+	sort.Slice(redundant, func(i, j int) bool {
+		depthI := strings.Count(redundant[i], string(filepath.Separator))
+		depthJ := strings.Count(redundant[j], string(filepath.Separator))
+		if depthI != depthJ {
+			return depthI < depthJ
+		}
+		return redundant[i] < redundant[j]
+	})
+
+	var accepted []string
+	isAccepted := func(path string) bool {
+		for _, dir := range accepted {
+			if isUnderDir(path, dir) {
+				return true
+			}
+		}
+		return false
+	}
+	groupIndexes := make(map[string]int)
+	for _, dir := range redundant {
+		kept := keptDirs[dir]
+		if isAccepted(dir) || isAccepted(kept) || !hasCopies(dir, contents[dir], accepted, contentIDs, groups) || !isPlainDir(dir) {
+			continue
+		}
+		accepted = append(accepted, dir)
+
+		dirPath, err := NewFilePath(dir)
+		if err != nil {
+			continue
+		}
+		dirPath.Metadata.Duplicate = true
+		dirPath.Metadata.Kept = kept
+		dirPath.Metadata.Contents = contents[dir]
+
+		i, ok := groupIndexes[kept]
+		if !ok {
+			keptPath, err := NewFilePath(kept)
+			if err != nil {
+				continue
+			}
+			keptPath.Metadata.Contents = contents[kept]
+			i = len(dirGroups)
+			groupIndexes[kept] = i
+			dirGroups = append(dirGroups, FilePaths{*keptPath})
+		}
+		dirGroups[i] = append(dirGroups[i], *dirPath)
+	}
+
+	sort.Slice(dirGroups, func(i, j int) bool {
+		return dirGroups[i][0].Path < dirGroups[j][0].Path
+	})
+	for _, group := range dirGroups {
+		sort.Sort(group[1:])
+	}
+
+	return dirGroups
+}
+
+// RemoveDirContents returns a copy of groups without the files contained in
+// the redundant directories in dirGroups. Groups which are left with fewer than
+// two files are omitted.
+func RemoveDirContents(groups []FilePaths, dirGroups []FilePaths) (output []FilePaths) {
+	removed := make(map[string]bool)
+	for _, dirGroup := range dirGroups {
+		for _, dirPath := range dirGroup[1:] {
+			for _, path := range dirPath.Metadata.Contents {
+				removed[path.Path] = true
+			}
+		}
+	}
+
+	for _, group := range groups {
+		newGroup := make(FilePaths, 0, len(group))
+		for _, path := range group {
+			if !removed[path.Path] {
+				newGroup = append(newGroup, path)
+			}
+		}
+		if len(newGroup) > 1 {
+			output = append(output, newGroup)
+		}
+	}
+
+	return output
+}
+
+// dirSignature returns a string which is the same for two directories if and
+// only if they contain files with the same relative paths and contents.
+func dirSignature(dir string, files FilePaths, contentIDs map[string]int) string {
+	entries := make([]string, 0, len(files))
+	for _, path := range files {
+		relPath, _ := filepath.Rel(dir, path.Path)
+		entries = append(entries, fmt.Sprintf("%s\x00%d", relPath, contentIDs[path.Path]))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// findSuperset returns the directory with the fewest files which contains
+// every file in dir with the same relative path and contents and at least one
+// other file. Directories which contain dir or are contained by it are
+// ignored. If there is no such directory, an empty string is returned.
+func findSuperset(dir string, contents map[string]FilePaths, contentIDs map[string]int, groups []FilePaths) string {
+	files := contents[dir]
+	firstRel, _ := filepath.Rel(dir, files[0].Path)
+	suffix := string(filepath.Separator) + firstRel
+
+	var superset string
+	for _, duplicate := range groups[contentIDs[files[0].Path]] {
+		if !strings.HasSuffix(duplicate.Path, suffix) {
+			continue
+		}
+		other := strings.TrimSuffix(duplicate.Path, suffix)
+		if other == dir || isUnderDir(other, dir) || isUnderDir(dir, other) {
+			continue
+		}
+		if len(contents[other]) <= len(files) {
+			continue
+		}
+		if superset != "" && len(contents[other]) >= len(contents[superset]) {
+			continue
+		}
+
+		otherIDs := make(map[string]int)
+		for _, path := range contents[other] {
+			if id, ok := contentIDs[path.Path]; ok {
+				otherIDs[path.Path] = id
+			}
+		}
+		contained := true
+		for _, path := range files {
+			relPath, _ := filepath.Rel(dir, path.Path)
+			id, ok := otherIDs[filepath.Join(other, relPath)]
+			if !ok || id != contentIDs[path.Path] {
+				contained = false
+				break
+			}
+		}
+		if contained {
+			superset = other
+		}
+	}
+
+	return superset
+}
+
+// hasCopies returns true if every file in dir has an identical copy which is
+// neither in dir nor in any of the directories in excludedDirs.
+func hasCopies(dir string, files FilePaths, excludedDirs []string, contentIDs map[string]int, groups []FilePaths) bool {
+	for _, path := range files {
+		found := false
+		for _, duplicate := range groups[contentIDs[path.Path]] {
+			if isUnderDir(duplicate.Path, dir) {
+				continue
+			}
+			excluded := false
+			for _, excludedDir := range excludedDirs {
+				if isUnderDir(duplicate.Path, excludedDir) {
+					excluded = true
+					break
+				}
+			}
+			if !excluded {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPlainDir returns true if the directory at path contains only regular files
+// and directories.
+func isPlainDir(path string) bool {
+	err := filepath.Walk(path, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return errSpecialFile
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// isUnderDir returns true if path is dir or is contained in dir.
+func isUnderDir(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"path/filepath"
+)
+
+func TestFindDuplicateDirs(t *testing.T) {
+	tempPath, teardownFunc := setupTempDir(t)
+	defer teardownFunc()
+	os.Chdir(tempPath)
+
+	for _, dir := range []string{"copy/sub", "project/sub", "old/sub", "other"} {
+		os.MkdirAll(dir, 0700)
+	}
+	contents := fileContents {
+		{"project/a.txt", "aaa"},
+		{"project/c.txt", "ccc"},
+		{"project/sub/b.txt", "bbb"},
+		{"copy/a.txt", "aaa"},
+		{"copy/c.txt", "ccc"},
+		{"copy/sub/b.txt", "bbb"},
+		{"old/a.txt", "aaa"},
+		{"old/sub/b.txt", "bbb"},
+		{"other/a.txt", "aaa"},
+		{"other/x.txt", "xxx"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allPaths, err := ScanTree(tempPath, ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := NewDuplicateFinder().Find(allPaths)
+
+	// The directory "other" contains a unique file, so it isn't redundant. The
+	// "sub" directories are redundant, but only their parents are returned.
+	dirGroups := FindDuplicateDirs(groups, allPaths, allPaths, tempPath, nil)
+	if len(dirGroups) != 1 {
+		t.Fatalf("%v groups != 1", len(dirGroups))
+	}
+	if dirGroups[0][0].Path != filepath.Join(tempPath, "copy") {
+		t.Errorf("kept %v != copy", dirGroups[0][0].Path)
+	}
+	assertPathsEqual(t, dirGroups[0][1:], []string{"old", "project"}, tempPath)
+	for _, dirPath := range dirGroups[0][1:] {
+		if dirPath.Metadata.Kept != dirGroups[0][0].Path {
+			t.Errorf("%v is not kept", dirGroups[0][0].Path)
+		}
+	}
+	if size := WastedSize(dirGroups[0]); size != 15 {
+		t.Errorf("wasted size %v != 15", size)
+	}
+
+	// Only the copy of a.txt in "other" is left outside the kept directory.
+	remaining := RemoveDirContents(groups, dirGroups)
+	if len(remaining) != 1 {
+		t.Fatalf("%v remaining groups != 1", len(remaining))
+	}
+	assertPathsEqual(t, remaining[0], []string{"copy/a.txt", "other/a.txt"}, tempPath)
+
+	// Directories with files that can't be cleaned up aren't redundant, but
+	// their subdirectories may still be.
+	candidates := allPaths.Difference(FilePaths{{Path: filepath.Join(tempPath, "old/a.txt")}})
+	dirGroups = FindDuplicateDirs(groups, allPaths, candidates, tempPath, nil)
+	if len(dirGroups) != 2 {
+		t.Fatalf("%v groups != 2", len(dirGroups))
+	}
+	assertPathsEqual(t, dirGroups[0][1:], []string{"project"}, tempPath)
+	assertPathsEqual(t, dirGroups[1][1:], []string{"old/sub"}, tempPath)
+}
@@ -0,0 +1,513 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"sort"
+	"os"
+	"io"
+	"fmt"
+	"bytes"
+	"hash"
+	"hash/crc64"
+	"crypto/sha256"
+	"runtime"
+	"sync"
+	"path/filepath"
+)
+
+// HashAlgorithm is the name of an algorithm used to compare files.
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "sha256"
+	HashCRC64 HashAlgorithm = "crc64"
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// ParseHashAlgorithm returns the hash algorithm with the given name.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	switch algorithm := HashAlgorithm(name); algorithm {
+	case HashSHA256, HashCRC64:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("the hash algorithm '%s' is not supported", name)
+	}
+}
+
+// New returns a new hash.Hash which uses the algorithm.
+func (a HashAlgorithm) New() hash.Hash {
+	switch a {
+	case HashCRC64:
+		return crc64.New(crc64Table)
+	default:
+		return sha256.New()
+	}
+}
+
+const BlockSize int = 4096
+
+// This is the number of blocks at the start and end of each file that are
+// hashed before hashing the whole file.
+const partialBlocks = 4
+
+// This is the number of bytes that are read from a file at once when hashing.
+const readSize = 1024 * 1024
+
+// DuplicateFinder finds groups of identical files. Files are compared in
+// stages, each of which only considers the files that still match after the
+// last one: first by size, then by a hash of the blocks at the start and end of
+// each file, and then by a hash of the whole file.
+type DuplicateFinder struct {
+	// This is the number of files which are hashed concurrently.
+	Workers int
+
+	// This is the number of files which are read concurrently. It is separate
+	// from Workers so that I/O can be limited on slow disks while hashing uses
+	// every CPU. Two files which are compared byte for byte count as one.
+	IOLimit int
+
+	// This is the number of bytes at the start and end of each file that are
+	// hashed before hashing the whole file.
+	PartialSize int64
+
+	// This is the algorithm used to hash files. SHA-256 is the default, and
+	// CRC-64 is faster but is not a cryptographic hash.
+	Algorithm HashAlgorithm
+
+	// If this is true, files with the same hash are compared byte for byte
+	// before they are returned so that a hash collision can never cause files
+	// to be reported as duplicates.
+	VerifyBytes bool
+
+	// If this is true, the hash of each whole file is stored in the HashXattr
+	// extended attribute of the file and reused until the size or mtime of the
+	// file changes.
+	CacheHashes bool
+}
+
+// NewDuplicateFinder creates a new DuplicateFinder with default settings.
+func NewDuplicateFinder() *DuplicateFinder {
+	return &DuplicateFinder {
+		Workers: runtime.NumCPU(),
+		IOLimit: 4,
+		PartialSize: int64(partialBlocks * BlockSize),
+		Algorithm: HashSHA256,
+	}
+}
+
+// hashKey identifies a group of files which may be identical.
+type hashKey struct {
+	Size int64
+	Sum string
+}
+
+// hashFunc returns a hash of the file at path. Reads from the file must go
+// through the given semaphore.
+type hashFunc func(path string, ioLimit chan struct{}) ([]byte, error)
+
+// Find determines which of the given files are identical and returns them.
+// Each FilePaths slice in the slice that is returned represents a group of
+// identical files.
+func (d *DuplicateFinder) Find(paths FilePaths) (duplicates []FilePaths) {
+	// Group files by size.
+	sizes := make(map[hashKey]FilePaths)
+	for _, path := range paths {
+		key := hashKey{Size: path.Stat.Size()}
+		sizes[key] = append(sizes[key], path)
+	}
+
+	// Group files with the same size by the hash of their first and last
+	// blocks. Files which are small enough to be hashed completely are done.
+	partialGroups := d.regroup(sizes, func(path string, ioLimit chan struct{}) ([]byte, error) {
+		return partialChecksum(path, d.Algorithm, d.PartialSize, ioLimit)
+	})
+	fullGroups := make(map[hashKey]FilePaths)
+	for key, group := range partialGroups {
+		if key.Size <= 2 * d.PartialSize {
+			duplicates = append(duplicates, group)
+		} else {
+			fullGroups[key] = group
+		}
+	}
+
+	// Group the remaining files by the hash of the whole file.
+	fullGroups = d.regroup(fullGroups, func(path string, ioLimit chan struct{}) ([]byte, error) {
+		if d.CacheHashes {
+			return cachedChecksum(path, d.Algorithm, ioLimit)
+		}
+		return checksum(path, d.Algorithm, ioLimit)
+	})
+	for _, group := range fullGroups {
+		duplicates = append(duplicates, group)
+	}
+
+	// Compare the files in each group byte for byte if applicable.
+	if d.VerifyBytes {
+		duplicates = d.verify(duplicates)
+	}
+
+	// Set a piece of metadata to differentiate these files as duplicates and
+	// sort them so that the output for a given input is always the same.
+	for i := range duplicates {
+		for j := range duplicates[i] {
+			duplicates[i][j].Metadata.Duplicate = true
+		}
+		sort.Sort(duplicates[i])
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i][0].Path < duplicates[j][0].Path
+	})
+
+	return duplicates
+}
+
+// regroup splits each group of files with more than one member into smaller
+// groups based on the hash returned by hash. Files which are the only member of
+// their group and files which can't be hashed are discarded.
+func (d *DuplicateFinder) regroup(groups map[hashKey]FilePaths, hash hashFunc) map[hashKey]FilePaths {
+	var toHash FilePaths
+	for _, group := range groups {
+		if len(group) > 1 {
+			toHash = append(toHash, group...)
+		}
+	}
+
+	sums := d.hashAll(toHash, hash)
+
+	newGroups := make(map[hashKey]FilePaths)
+	for _, path := range toHash {
+		sum, ok := sums[path.Path]
+		if !ok {
+			continue
+		}
+		key := hashKey{Size: path.Stat.Size(), Sum: string(sum)}
+		newGroups[key] = append(newGroups[key], path)
+	}
+
+	for key, group := range newGroups {
+		if len(group) < 2 {
+			delete(newGroups, key)
+		}
+	}
+
+	return newGroups
+}
+
+// hashAll concurrently hashes the given files and returns a map of file paths
+// to hashes. Files which can't be hashed are omitted.
+func (d *DuplicateFinder) hashAll(paths FilePaths, hash hashFunc) map[string][]byte {
+	jobs := make(chan string, 100)
+	ioLimit := make(chan struct{}, maxInt(d.IOLimit, 1))
+	sums := make(map[string][]byte)
+	var sumsLock sync.Mutex
+	var wg sync.WaitGroup
+
+	// Start workers.
+	for i := 0; i < maxInt(d.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				sum, err := hash(path, ioLimit)
+				if err != nil {
+					continue
+				}
+
+				sumsLock.Lock()
+				sums[path] = sum
+				sumsLock.Unlock()
+			}
+		}()
+	}
+
+	// Pass file paths to workers.
+	for _, path := range paths {
+		jobs <- path.Path
+	}
+	close(jobs)
+	wg.Wait()
+
+	return sums
+}
+
+// maxInt returns the larger of two integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// acquire waits for a slot in the semaphore ioLimit and returns a function
+// which releases it. A slot is held for the whole time that a file is open so
+// that the semaphore limits how many files are read at once.
+func acquire(ioLimit chan struct{}) (release func()) {
+	ioLimit <- struct{}{}
+	return func() { <-ioLimit }
+}
+
+// verify compares the files in each group byte for byte and splits groups
+// whose files are not identical. Groups are compared concurrently, and groups
+// which are left with only one file are discarded.
+func (d *DuplicateFinder) verify(groups []FilePaths) (verified []FilePaths) {
+	jobs := make(chan FilePaths, 100)
+	ioLimit := make(chan struct{}, maxInt(d.IOLimit, 1))
+	var verifiedLock sync.Mutex
+	var wg sync.WaitGroup
+
+	// Start workers.
+	for i := 0; i < maxInt(d.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range jobs {
+				subgroups := splitByContents(group, ioLimit)
+
+				verifiedLock.Lock()
+				verified = append(verified, subgroups...)
+				verifiedLock.Unlock()
+			}
+		}()
+	}
+
+	// Pass groups to workers.
+	for _, group := range groups {
+		jobs <- group
+	}
+	close(jobs)
+	wg.Wait()
+
+	return verified
+}
+
+// splitByContents splits a group of files into groups of files whose contents
+// are identical. Groups with only one file and files which can't be read are
+// discarded.
+func splitByContents(group FilePaths, ioLimit chan struct{}) (output []FilePaths) {
+	var subgroups []FilePaths
+	for _, path := range group {
+		matched := false
+		for i := range subgroups {
+			identical, err := compareFiles(subgroups[i][0].Path, path.Path, ioLimit)
+			if err != nil {
+				matched = true
+				break
+			}
+			if identical {
+				subgroups[i] = append(subgroups[i], path)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			subgroups = append(subgroups, FilePaths{path})
+		}
+	}
+
+	for _, subgroup := range subgroups {
+		if len(subgroup) > 1 {
+			output = append(output, subgroup)
+		}
+	}
+
+	return output
+}
+
+// compareFiles returns true if the files at pathA and pathB have identical
+// contents. Both files are read while holding a single slot in the semaphore
+// ioLimit.
+func compareFiles(pathA, pathB string, ioLimit chan struct{}) (identical bool, err error) {
+	defer acquire(ioLimit)()
+
+	fileA, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fileA.Close()
+
+	fileB, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fileB.Close()
+
+	bufferA, bufferB := make([]byte, readSize), make([]byte, readSize)
+	for {
+		sizeA, errA := io.ReadFull(fileA, bufferA)
+		sizeB, errB := io.ReadFull(fileB, bufferB)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+
+		if !bytes.Equal(bufferA[:sizeA], bufferB[:sizeB]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
+}
+
+// checksum returns the hash of a given file using algorithm. The file is read
+// while holding a slot in the semaphore ioLimit.
+func checksum(path string, algorithm HashAlgorithm, ioLimit chan struct{}) (checksum []byte, err error) {
+	defer acquire(ioLimit)()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := algorithm.New()
+	buffer := make([]byte, readSize)
+	if _, err := io.CopyBuffer(hash, file, buffer); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// partialChecksum returns the hash of the first and last partialSize bytes of
+// a given file using algorithm. The file is read while holding a slot in the
+// semaphore ioLimit.
+func partialChecksum(path string, algorithm HashAlgorithm, partialSize int64, ioLimit chan struct{}) (checksum []byte, err error) {
+	defer acquire(ioLimit)()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := algorithm.New()
+	if info.Size() <= 2 * partialSize {
+		// The whole file fits in the partial hash.
+		if _, err := io.Copy(hash, file); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := io.CopyN(hash, file, partialSize); err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(-partialSize, io.SeekEnd); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(hash, file, partialSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// WastedSize returns the number of bytes used by every file in a group of
+// duplicates except the first one, which is kept. The files in a directory
+// which is handled as a single unit are counted individually. Hard links to a
+// file which was already counted use no more space, so they aren't counted.
+func WastedSize(group FilePaths) (wasted int64) {
+	var counted []os.FileInfo
+	isCounted := func(info os.FileInfo) bool {
+		for _, countedInfo := range counted {
+			if os.SameFile(info, countedInfo) {
+				return true
+			}
+		}
+		return false
+	}
+	files := func(path FilePath) FilePaths {
+		if path.Metadata.Contents != nil {
+			return path.Metadata.Contents
+		}
+		return FilePaths{path}
+	}
+
+	for i, path := range group {
+		for _, file := range files(path) {
+			if file.Stat != nil && isCounted(file.Stat) {
+				continue
+			}
+			if i > 0 {
+				wasted += file.Stat.Size()
+			}
+			counted = append(counted, file.Stat)
+		}
+	}
+	return wasted
+}
+
+// CheckSpan returns true if the group of files has at least one file under
+// each of the directories in dirs. A directory in the group counts as being
+// under itself.
+func CheckSpan(group FilePaths, dirs []string) bool {
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return false
+		}
+
+		found := false
+		for _, path := range group {
+			absPath, err := filepath.Abs(path.Path)
+			if err == nil && isUnderDir(absPath, absDir) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetDuplicates determines which of the given files are identical and returns
+// them. Each FilePaths slice in the slice that is returned represents a group
+// of identical files. Files are compared using a DuplicateFinder with the
+// default settings.
+func GetDuplicates(paths FilePaths) (duplicates []FilePaths) {
+	return NewDuplicateFinder().Find(paths)
+}
+
+// GetOldestDuplicates returns all duplicate files as a single slice, but omits
+// the file with the most recent mtime for each group of duplicates.
+func GetOldestDuplicates(paths FilePaths) (duplicates FilePaths) {
+	return OldestDuplicates(GetDuplicates(paths))
+}
+
+// OldestDuplicates is like GetOldestDuplicates, but it accepts groups of
+// duplicates returned by GetDuplicates. Each group is sorted in place so that
+// the file which is kept comes first.
+func OldestDuplicates(groups []FilePaths) (duplicates FilePaths) {
+	return KeepDuplicates(groups, []KeepRule{KeepNewest})
+}
@@ -0,0 +1,278 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"time"
+)
+
+func TestGetDuplicates(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicatePaths := GetDuplicates(*pathsToTest)
+	expectedPaths := []string{"letters/a.txt", "letters/upper/A.txt"}
+
+	assertPathsEqual(t, duplicatePaths[0], expectedPaths, tempPath)
+}
+
+func TestGetOldestDuplicates(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the mtime to a time in the past.
+	os.Chtimes("letters/upper/A.txt", time.Now(), time.Now().Add(-time.Second))
+
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicatePaths := GetOldestDuplicates(*pathsToTest)
+	expectedPaths := []string{"letters/upper/A.txt"}
+
+	assertPathsEqual(t, duplicatePaths, expectedPaths, tempPath)
+}
+
+func TestDuplicateFinderStages(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	testCases := []struct {
+		TestName string
+		Contents fileContents
+		ExpectedGroups int
+	}{
+		{"Same middle", fileContents {
+			{"letters/a.txt", "aXXXb"},
+			{"letters/upper/A.txt", "aXXXb"},
+			{"numbers/1.txt", "aXYXb"},
+		}, 1},
+		{"Different ends", fileContents {
+			{"letters/a.txt", "aXXXb"},
+			{"letters/upper/A.txt", "aXXXc"},
+			{"numbers/1.txt", "cXXXb"},
+		}, 0},
+		{"Small files", fileContents {
+			{"letters/a.txt", "ab"},
+			{"letters/upper/A.txt", "ab"},
+			{"numbers/1.txt", "ac"},
+		}, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			err := writeFiles(tc.Contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Only hash one byte at each end of the file before hashing the
+			// whole file so that every stage is used.
+			finder := NewDuplicateFinder()
+			finder.PartialSize = 1
+			finder.IOLimit = 1
+			duplicates := finder.Find(*pathsToTest)
+
+			if len(duplicates) != tc.ExpectedGroups {
+				t.Fatalf("%d groups != %d groups: %v", len(duplicates), tc.ExpectedGroups, duplicates)
+			}
+			if tc.ExpectedGroups > 0 {
+				expectedPaths := []string{"letters/a.txt", "letters/upper/A.txt"}
+				assertPathsEqual(t, duplicates[0], expectedPaths, tempPath)
+			}
+		})
+	}
+}
+
+func TestDuplicateFinderOptions(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		TestName string
+		Algorithm HashAlgorithm
+		VerifyBytes bool
+	}{
+		{"SHA-256", HashSHA256, false},
+		{"CRC-64", HashCRC64, false},
+		{"Verify bytes", HashCRC64, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			finder := NewDuplicateFinder()
+			finder.Algorithm = tc.Algorithm
+			finder.VerifyBytes = tc.VerifyBytes
+			duplicates := finder.Find(*pathsToTest)
+			expectedPaths := []string{"letters/a.txt", "letters/upper/A.txt"}
+
+			if len(duplicates) != 1 {
+				t.Fatalf("%d groups != 1 group", len(duplicates))
+			}
+			assertPathsEqual(t, duplicates[0], expectedPaths, tempPath)
+		})
+	}
+}
+
+func TestSplitByContents(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	// Pretend that these files were reported as duplicates because of a hash
+	// collision.
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "bbb"},
+		{"numbers/1.txt", "aaa"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := splitByContents(*pathsToTest, make(chan struct{}, 1))
+	expectedPaths := []string{"letters/a.txt", "numbers/1.txt"}
+
+	if len(groups) != 1 {
+		t.Fatalf("%d groups != 1 group", len(groups))
+	}
+	assertPathsEqual(t, groups[0], expectedPaths, tempPath)
+}
+
+func TestWastedSize(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "aaa"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wasted := WastedSize(*group); wasted != 6 {
+		t.Errorf("%d bytes wasted != 6 bytes", wasted)
+	}
+	if wasted := WastedSize(nil); wasted != 0 {
+		t.Errorf("%d bytes wasted != 0 bytes", wasted)
+	}
+
+	// Hard links to a file which was already counted don't waste any space.
+	if err := os.Link("letters/a.txt", "a-link.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link("numbers/1.txt", "1-link.txt"); err != nil {
+		t.Fatal(err)
+	}
+	linkedGroup, err := NewFilePathsFromRel(
+		[]string{"letters/a.txt", "a-link.txt", "numbers/1.txt", "1-link.txt"}, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wasted := WastedSize(*linkedGroup); wasted != 3 {
+		t.Errorf("%d bytes wasted != 3 bytes", wasted)
+	}
+}
+
+func TestCheckSpan(t *testing.T) {
+	group := FilePaths {
+		{Path: "/dir/letters/a.txt"},
+		{Path: "/dir/letters/upper/A.txt"},
+		{Path: "/dir/numbers/1.txt"},
+	}
+
+	testCases := []struct {
+		Dirs []string
+		Spans bool
+	}{
+		{[]string{"/dir/letters", "/dir/numbers"}, true},
+		{[]string{"/dir/letters/upper"}, true},
+		{[]string{"/dir/letters", "/dir/empty"}, false},
+		{[]string{"/dir/num"}, false},
+		{[]string{"/"}, true},
+		{[]string{"/", "/dir/numbers/"}, true},
+		{nil, true},
+	}
+
+	for _, tc := range testCases {
+		if result := CheckSpan(group, tc.Dirs); result != tc.Spans {
+			t.Errorf("Dirs: %v", tc.Dirs)
+		}
+	}
+}
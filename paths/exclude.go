@@ -63,6 +63,13 @@ func NewExcludeFromFile(path string) (*Exclude, error) {
 // CheckMatch returns true if the given file path matches any pattern relative
 // to startDir. Otherwise, it returns false.
 func (e *Exclude) CheckMatch(checkPath string, startDir string) (matched bool) {
+	_, matched = e.MatchingPattern(checkPath, startDir)
+	return matched
+}
+
+// MatchingPattern returns the first pattern that the given file path matches
+// relative to startDir. If it matches no pattern, matched is false.
+func (e *Exclude) MatchingPattern(checkPath string, startDir string) (pattern string, matched bool) {
 	for _, relPattern := range e.Patterns {
 		var absPatterns []string
 
@@ -82,10 +89,10 @@ func (e *Exclude) CheckMatch(checkPath string, startDir string) (matched bool) {
 			}
 
 			if matched {
-				return true
+				return relPattern, true
 			}
 		}
 	}
 
-	return false
+	return "", false
 }
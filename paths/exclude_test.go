@@ -94,3 +94,25 @@ func TestCheckMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchingPattern(t *testing.T) {
+	exclude := Exclude{Patterns: excludeTestPatterns}
+
+	testCases := []struct {
+		CheckPath string
+		Pattern string
+		Matches bool
+	}{
+		{"/dir/foo/thesis.odt", "*.odt", true},
+		{"/dir/documents/reports/foo/essay.pdf", "/documents/reports", true},
+		{"/dir/documents/foo.pdf", "", false},
+	}
+
+	for _, tc := range testCases {
+		pattern, matched := exclude.MatchingPattern(tc.CheckPath, "/dir")
+
+		if pattern != tc.Pattern || matched != tc.Matches {
+			t.Errorf("Path: %v, Pattern: %v", tc.CheckPath, pattern)
+		}
+	}
+}
@@ -0,0 +1,888 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"fmt"
+	"strings"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar"
+
+	"github.com/lostatc/reddup/parse"
+)
+
+// ExpressionError is returned when an expression can't be parsed. Column is
+// the 1-based column in Source where the problem was found.
+type ExpressionError struct {
+	Source string
+	Column int
+	Message string
+}
+
+// Error satisfies the error interface. The message includes the expression
+// with a marker under the column where the problem was found.
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf(
+		"invalid expression at column %d: %s\n    %s\n    %s^",
+		e.Column, e.Message, e.Source, strings.Repeat(" ", e.Column - 1))
+}
+
+// Expression is a parsed filter expression which can be evaluated for each
+// file, such as `size > 1GiB && atime < now - 90d && ext in ["mkv", "iso"]`.
+type Expression struct {
+	Source string
+	root exprNode
+	now time.Time
+}
+
+// ParseExpression parses and type checks a filter expression.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{source: source, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.Kind != tokenEOF {
+		return nil, p.errorf(next.Column, "unexpected '%s'", next.Text)
+	}
+	if root.Type() != typeBool {
+		return nil, p.errorf(root.Column(), "the expression must be true or false, not a %v", root.Type())
+	}
+
+	return &Expression{Source: source, root: root, now: time.Now()}, nil
+}
+
+// Evaluate returns true if the file path matches the expression. Paths are
+// evaluated relative to startDir.
+func (e *Expression) Evaluate(path FilePath, startDir string) bool {
+	ctx := &evalContext{path: path, startDir: startDir, now: e.now}
+	return e.root.eval(ctx).(bool)
+}
+
+// Filter returns the file paths that match the expression. Paths are
+// evaluated relative to startDir. A directory which is handled as a single unit
+// matches only if every file in its Metadata.Contents matches.
+func (e *Expression) Filter(paths FilePaths, startDir string) FilePaths {
+	output := make(FilePaths, 0)
+	for _, path := range paths {
+		if path.Metadata.Contents == nil {
+			if e.Evaluate(path, startDir) {
+				output = append(output, path)
+			}
+		} else if len(e.Filter(path.Metadata.Contents, startDir)) == len(path.Metadata.Contents) {
+			output = append(output, path)
+		}
+	}
+	return output
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuantity
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	Kind tokenKind
+	Text string
+	Column int
+}
+
+// These are sorted so that longer operators are matched first.
+var expressionOperators = []string {
+	"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "~", "+", "-", "(", ")", "[", "]", ",",
+}
+
+// lexExpression splits an expression into tokens.
+func lexExpression(source string) ([]token, error) {
+	var tokens []token
+	column := func(i int) int {
+		return utf8.RuneCountInString(source[:i]) + 1
+	}
+
+	for i := 0; i < len(source); {
+		char, width := utf8.DecodeRuneInString(source[i:])
+
+		switch {
+		case unicode.IsSpace(char):
+			i += width
+
+		case char == '_' || unicode.IsLetter(char):
+			start := i
+			for i < len(source) {
+				next, nextWidth := utf8.DecodeRuneInString(source[i:])
+				if !isWordRune(next) {
+					break
+				}
+				i += nextWidth
+			}
+			tokens = append(tokens, token{tokenIdent, source[start:i], column(start)})
+
+		case unicode.IsDigit(char):
+			start := i
+			for i < len(source) {
+				next, nextWidth := utf8.DecodeRuneInString(source[i:])
+				if !isWordRune(next) {
+					break
+				}
+				i += nextWidth
+			}
+			tokens = append(tokens, token{tokenQuantity, source[start:i], column(start)})
+
+		case char == '"':
+			start := i
+			for i++; i < len(source) && source[i] != '"'; i++ {
+				if source[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(source) {
+				return nil, &ExpressionError{source, column(start), "unterminated string"}
+			}
+			i++
+			text, err := strconv.Unquote(source[start:i])
+			if err != nil {
+				return nil, &ExpressionError{source, column(start), "invalid string"}
+			}
+			tokens = append(tokens, token{tokenString, text, column(start)})
+
+		default:
+			matched := false
+			for _, operator := range expressionOperators {
+				if strings.HasPrefix(source[i:], operator) {
+					tokens = append(tokens, token{tokenOperator, operator, column(i)})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &ExpressionError{source, column(i), fmt.Sprintf("unexpected character '%c'", char)}
+			}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "end of expression", column(len(source))}), nil
+}
+
+// isWordRune returns true if r can be part of an identifier or quantity.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeString
+	typeTime
+	typeDuration
+	typeList
+	// This is the type of a literal like "10m" whose unit depends on what it
+	// is compared with.
+	typeQuantity
+)
+
+// String returns the default string representation of the type. This satisfies
+// the fmt.Stringer interface.
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "boolean"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeTime:
+		return "time"
+	case typeDuration:
+		return "duration"
+	case typeList:
+		return "list"
+	default:
+		return "quantity"
+	}
+}
+
+// evalContext holds the file that an expression is being evaluated for.
+type evalContext struct {
+	path FilePath
+	startDir string
+	now time.Time
+}
+
+// exprNode is a node in the syntax tree of an expression. Values returned by
+// eval are a bool, int64, string, time.Time, time.Duration or []interface{}
+// depending on the type of the node.
+type exprNode interface {
+	Type() valueType
+	Column() int
+	eval(ctx *evalContext) interface{}
+}
+
+// These are the fields that can be used in expressions and their types.
+var expressionFields = map[string]valueType {
+	"size": typeNumber,
+	"atime": typeTime,
+	"mtime": typeTime,
+	"ctime": typeTime,
+	"btime": typeTime,
+	"now": typeTime,
+	"path": typeString,
+	"name": typeString,
+	"ext": typeString,
+	"owner": typeString,
+	"mode": typeNumber,
+	"depth": typeNumber,
+	"duplicate": typeBool,
+}
+
+type literalNode struct {
+	value interface{}
+	typ valueType
+	column int
+}
+
+func (n *literalNode) Type() valueType {
+	return n.typ
+}
+
+func (n *literalNode) Column() int {
+	return n.column
+}
+
+func (n *literalNode) eval(ctx *evalContext) interface{} {
+	return n.value
+}
+
+type quantityNode struct {
+	text string
+	column int
+}
+
+func (n *quantityNode) Type() valueType {
+	return typeQuantity
+}
+
+func (n *quantityNode) Column() int {
+	return n.column
+}
+
+func (n *quantityNode) eval(ctx *evalContext) interface{} {
+	panic("unresolved quantity")
+}
+
+type fieldNode struct {
+	name string
+	column int
+}
+
+func (n *fieldNode) Type() valueType {
+	return expressionFields[n.name]
+}
+
+func (n *fieldNode) Column() int {
+	return n.column
+}
+
+func (n *fieldNode) eval(ctx *evalContext) interface{} {
+	path := ctx.path
+	switch n.name {
+	case "size":
+		return path.Stat.Size()
+	case "atime":
+		return path.Time.AccessTime()
+	case "mtime":
+		return path.Time.ModTime()
+	case "ctime":
+		if path.Time.HasChangeTime() {
+			return path.Time.ChangeTime()
+		}
+		return time.Time{}
+	case "btime":
+		if path.Time.HasBirthTime() {
+			return path.Time.BirthTime()
+		}
+		return time.Time{}
+	case "now":
+		return ctx.now
+	case "path":
+		return path.Path
+	case "name":
+		return filepath.Base(path.Path)
+	case "ext":
+		return strings.TrimPrefix(filepath.Ext(path.Path), ".")
+	case "owner":
+		return getOwner(path.Stat)
+	case "mode":
+		return int64(path.Stat.Mode().Perm())
+	case "depth":
+		relPath, err := filepath.Rel(ctx.startDir, path.Path)
+		if err != nil {
+			return int64(0)
+		}
+		return int64(len(strings.Split(relPath, string(filepath.Separator))))
+	case "duplicate":
+		return path.Metadata.Duplicate
+	default:
+		panic("unknown field")
+	}
+}
+
+type notNode struct {
+	operand exprNode
+	column int
+}
+
+func (n *notNode) Type() valueType {
+	return typeBool
+}
+
+func (n *notNode) Column() int {
+	return n.column
+}
+
+func (n *notNode) eval(ctx *evalContext) interface{} {
+	return !n.operand.eval(ctx).(bool)
+}
+
+type binaryNode struct {
+	operator string
+	left, right exprNode
+	typ valueType
+	column int
+}
+
+func (n *binaryNode) Type() valueType {
+	return n.typ
+}
+
+func (n *binaryNode) Column() int {
+	return n.column
+}
+
+func (n *binaryNode) eval(ctx *evalContext) interface{} {
+	switch n.operator {
+	case "&&":
+		return n.left.eval(ctx).(bool) && n.right.eval(ctx).(bool)
+	case "||":
+		return n.left.eval(ctx).(bool) || n.right.eval(ctx).(bool)
+	case "+", "-":
+		return evalArithmetic(n.operator, n.left.eval(ctx), n.right.eval(ctx))
+	case "~":
+		matched, _ := doublestar.Match(n.right.eval(ctx).(string), n.left.eval(ctx).(string))
+		return matched
+	case "in":
+		left := n.left.eval(ctx)
+		for _, element := range n.right.eval(ctx).([]interface{}) {
+			if compareValues(left, element) == 0 {
+				return true
+			}
+		}
+		return false
+	default:
+		result := compareValues(n.left.eval(ctx), n.right.eval(ctx))
+		switch n.operator {
+		case "==":
+			return result == 0
+		case "!=":
+			return result != 0
+		case "<":
+			return result < 0
+		case "<=":
+			return result <= 0
+		case ">":
+			return result > 0
+		default:
+			return result >= 0
+		}
+	}
+}
+
+type listNode struct {
+	elements []exprNode
+	column int
+}
+
+func (n *listNode) Type() valueType {
+	return typeList
+}
+
+func (n *listNode) Column() int {
+	return n.column
+}
+
+func (n *listNode) eval(ctx *evalContext) interface{} {
+	values := make([]interface{}, len(n.elements))
+	for i, element := range n.elements {
+		values[i] = element.eval(ctx)
+	}
+	return values
+}
+
+// compareValues returns a negative number if left is less than right, zero if
+// they are equal and a positive number if left is greater than right. Both
+// values must have the same type.
+func compareValues(left, right interface{}) int {
+	switch left := left.(type) {
+	case bool:
+		if left == right.(bool) {
+			return 0
+		}
+		return 1
+	case int64:
+		return compareInts(left, right.(int64))
+	case time.Duration:
+		return compareInts(int64(left), int64(right.(time.Duration)))
+	case time.Time:
+		return compareInts(left.UnixNano(), right.(time.Time).UnixNano())
+	default:
+		return strings.Compare(left.(string), right.(string))
+	}
+}
+
+// compareInts compares two integers like compareValues.
+func compareInts(left, right int64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// evalArithmetic adds or subtracts two values whose types were checked by
+// checkArithmetic.
+func evalArithmetic(operator string, left, right interface{}) interface{} {
+	sign := int64(1)
+	if operator == "-" {
+		sign = -1
+	}
+
+	switch left := left.(type) {
+	case int64:
+		return left + sign * right.(int64)
+	case time.Time:
+		if right, ok := right.(time.Time); ok {
+			return left.Sub(right)
+		}
+		return left.Add(time.Duration(sign) * right.(time.Duration))
+	default:
+		if right, ok := right.(time.Time); ok {
+			return right.Add(left.(time.Duration))
+		}
+		return left.(time.Duration) + time.Duration(sign) * right.(time.Duration)
+	}
+}
+
+// exprParser is a recursive descent parser for expressions. It type checks
+// nodes as it creates them.
+type exprParser struct {
+	source string
+	tokens []token
+	pos int
+}
+
+// errorf returns an ExpressionError for the given column.
+func (p *exprParser) errorf(column int, format string, args ...interface{}) error {
+	return &ExpressionError{p.source, column, fmt.Sprintf(format, args...)}
+}
+
+// peek returns the next token without consuming it.
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *exprParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token and returns true if it is the given
+// operator or keyword.
+func (p *exprParser) accept(text string) bool {
+	if tok := p.peek(); (tok.Kind == tokenOperator || tok.Kind == tokenIdent) && tok.Text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses expressions joined by "||".
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator := p.peek()
+		if !p.accept("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.checkLogical(operator, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseAnd parses expressions joined by "&&".
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator := p.peek()
+		if !p.accept("&&") {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.checkLogical(operator, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseNot parses an expression which may be negated with "!".
+func (p *exprParser) parseNot() (exprNode, error) {
+	operator := p.peek()
+	if !p.accept("!") {
+		return p.parseComparison()
+	}
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if operand.Type() != typeBool {
+		return nil, p.errorf(operand.Column(), "'!' can't be applied to a %v", operand.Type())
+	}
+
+	return &notNode{operand: operand, column: operator.Column}, nil
+}
+
+// parseComparison parses an expression which may be compared with another.
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := p.peek()
+	switch {
+	case p.accept("=="), p.accept("!="), p.accept("<"), p.accept("<="), p.accept(">"), p.accept(">="):
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return p.checkComparison(operator, left, right)
+
+	case p.accept("~"):
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if left.Type() != typeString || right.Type() != typeString {
+			return nil, p.errorf(operator.Column, "'~' matches a string against a pattern string")
+		}
+		return &binaryNode{operator: "~", left: left, right: right, typ: typeBool, column: operator.Column}, nil
+
+	case p.accept("in"):
+		right, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return p.checkIn(operator, left, right)
+	}
+
+	return left, nil
+}
+
+// parseAdditive parses values joined by "+" or "-".
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator := p.peek()
+		if !p.accept("+") && !p.accept("-") {
+			return left, nil
+		}
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.checkArithmetic(operator, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parsePrimary parses a field, a literal or a parenthesized expression.
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+
+	switch tok.Kind {
+	case tokenIdent:
+		switch tok.Text {
+		case "true", "false":
+			return &literalNode{value: tok.Text == "true", typ: typeBool, column: tok.Column}, nil
+		}
+		if _, ok := expressionFields[tok.Text]; !ok {
+			return nil, p.errorf(tok.Column, "unknown field '%s'", tok.Text)
+		}
+		return &fieldNode{name: tok.Text, column: tok.Column}, nil
+
+	case tokenQuantity:
+		return &quantityNode{text: tok.Text, column: tok.Column}, nil
+
+	case tokenString:
+		return &literalNode{value: tok.Text, typ: typeString, column: tok.Column}, nil
+
+	case tokenOperator:
+		switch tok.Text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.peek(); !p.accept(")") {
+				return nil, p.errorf(closing.Column, "expected ')' but found '%s'", closing.Text)
+			}
+			return node, nil
+		case "[":
+			p.pos--
+			return p.parseList()
+		}
+	}
+
+	return nil, p.errorf(tok.Column, "expected a value but found '%s'", tok.Text)
+}
+
+// parseList parses a list of literals like `["mkv", "iso"]`.
+func (p *exprParser) parseList() (exprNode, error) {
+	opening := p.next()
+	if opening.Kind != tokenOperator || opening.Text != "[" {
+		return nil, p.errorf(opening.Column, "expected '[' but found '%s'", opening.Text)
+	}
+
+	list := &listNode{column: opening.Column}
+	if p.accept("]") {
+		return list, nil
+	}
+
+	for {
+		element := p.next()
+		switch element.Kind {
+		case tokenQuantity:
+			list.elements = append(list.elements, &quantityNode{text: element.Text, column: element.Column})
+		case tokenString:
+			list.elements = append(list.elements, &literalNode{value: element.Text, typ: typeString, column: element.Column})
+		default:
+			return nil, p.errorf(element.Column, "expected a string or number but found '%s'", element.Text)
+		}
+
+		if p.accept("]") {
+			return list, nil
+		}
+		if separator := p.peek(); !p.accept(",") {
+			return nil, p.errorf(separator.Column, "expected ',' or ']' but found '%s'", separator.Text)
+		}
+	}
+}
+
+// checkLogical type checks the operands of "&&" and "||".
+func (p *exprParser) checkLogical(operator token, left, right exprNode) (exprNode, error) {
+	for _, operand := range []exprNode{left, right} {
+		if operand.Type() != typeBool {
+			return nil, p.errorf(operand.Column(), "'%s' can't be applied to a %v", operator.Text, operand.Type())
+		}
+	}
+	return &binaryNode{operator: operator.Text, left: left, right: right, typ: typeBool, column: operator.Column}, nil
+}
+
+// checkComparison type checks the operands of a comparison operator.
+func (p *exprParser) checkComparison(operator token, left, right exprNode) (exprNode, error) {
+	left, right, err := p.unify(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	if left.Type() != right.Type() {
+		return nil, p.errorf(operator.Column, "a %v can't be compared with a %v", left.Type(), right.Type())
+	}
+	if left.Type() == typeList || (left.Type() == typeBool && operator.Text != "==" && operator.Text != "!=") {
+		return nil, p.errorf(operator.Column, "'%s' can't be applied to a %v", operator.Text, left.Type())
+	}
+
+	return &binaryNode{operator: operator.Text, left: left, right: right, typ: typeBool, column: operator.Column}, nil
+}
+
+// checkArithmetic type checks the operands of "+" and "-".
+func (p *exprParser) checkArithmetic(operator token, left, right exprNode) (exprNode, error) {
+	left, right, err := p.unify(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	var result valueType
+	switch {
+	case left.Type() == typeNumber && right.Type() == typeNumber:
+		result = typeNumber
+	case left.Type() == typeTime && right.Type() == typeDuration:
+		result = typeTime
+	case left.Type() == typeDuration && right.Type() == typeDuration:
+		result = typeDuration
+	case left.Type() == typeTime && right.Type() == typeTime && operator.Text == "-":
+		result = typeDuration
+	case left.Type() == typeDuration && right.Type() == typeTime && operator.Text == "+":
+		result = typeTime
+	default:
+		return nil, p.errorf(
+			operator.Column, "'%s' can't be applied to a %v and a %v",
+			operator.Text, left.Type(), right.Type())
+	}
+
+	return &binaryNode{operator: operator.Text, left: left, right: right, typ: result, column: operator.Column}, nil
+}
+
+// checkIn type checks the operands of "in".
+func (p *exprParser) checkIn(operator token, left, right exprNode) (exprNode, error) {
+	left, err := p.resolve(left, typeQuantity)
+	if err != nil {
+		return nil, err
+	}
+	if left.Type() == typeBool || left.Type() == typeList {
+		return nil, p.errorf(operator.Column, "'in' can't be applied to a %v", left.Type())
+	}
+
+	list := right.(*listNode)
+	for i, element := range list.elements {
+		element, err := p.resolve(element, left.Type())
+		if err != nil {
+			return nil, err
+		}
+		if element.Type() != left.Type() {
+			return nil, p.errorf(element.Column(), "a %v can't be compared with a %v", left.Type(), element.Type())
+		}
+		list.elements[i] = element
+	}
+
+	return &binaryNode{operator: "in", left: left, right: list, typ: typeBool, column: operator.Column}, nil
+}
+
+// unify resolves the units of quantities based on the type of the other
+// operand.
+func (p *exprParser) unify(left, right exprNode) (exprNode, exprNode, error) {
+	var err error
+	if left.Type() == typeQuantity {
+		if left, err = p.resolve(left, right.Type()); err != nil {
+			return nil, nil, err
+		}
+	}
+	if right.Type() == typeQuantity {
+		if right, err = p.resolve(right, left.Type()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return left, right, nil
+}
+
+// resolve converts a quantity to a literal based on the type it is used with.
+// Numbers and sizes like "1GiB" are read with parse.ReadFileSize and durations
+// like "90d" are read with parse.ReadDuration. If the type it is used with is
+// also a quantity, numbers and sizes are preferred. Other nodes are returned
+// unchanged.
+func (p *exprParser) resolve(node exprNode, other valueType) (exprNode, error) {
+	quantity, ok := node.(*quantityNode)
+	if !ok {
+		return node, nil
+	}
+
+	switch other {
+	case typeNumber:
+		number, err := readExpressionNumber(quantity.text)
+		if err != nil {
+			return nil, p.errorf(quantity.column, "'%s' is not a valid number or size", quantity.text)
+		}
+		return &literalNode{value: number, typ: typeNumber, column: quantity.column}, nil
+
+	case typeTime, typeDuration:
+		duration, err := parse.ReadDuration(quantity.text)
+		if err != nil {
+			return nil, p.errorf(quantity.column, "'%s' is not a valid duration", quantity.text)
+		}
+		return &literalNode{value: duration, typ: typeDuration, column: quantity.column}, nil
+
+	case typeQuantity:
+		if number, err := readExpressionNumber(quantity.text); err == nil {
+			return &literalNode{value: number, typ: typeNumber, column: quantity.column}, nil
+		}
+		return p.resolve(node, typeDuration)
+
+	default:
+		return nil, p.errorf(quantity.column, "a %v can't be compared with a number", other)
+	}
+}
+
+// readExpressionNumber reads a plain number or a file size. Plain numbers with
+// a leading zero are octal so that they can be compared with file modes.
+func readExpressionNumber(text string) (int64, error) {
+	if strings.Trim(text, "0123456789") == "" {
+		if len(text) > 1 && text[0] == '0' {
+			return strconv.ParseInt(text, 8, 64)
+		}
+		return strconv.ParseInt(text, 10, 64)
+	}
+	return parse.ReadFileSize(text)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"time"
+	"path/filepath"
+)
+
+func TestParseExpression(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Source string
+		ErrorColumn int
+	}{
+		{"Comparison", `size > 1GiB`, 0},
+		{"Time arithmetic", `atime < now - 90d && mtime >= now - 1y2m`, 0},
+		{"List", `ext in ["mkv", "iso"] && !duplicate`, 0},
+		{"Parentheses", `(size > 10m || depth <= 2) && mode == 0644`, 0},
+		{"Glob", `name ~ "*.iso"`, 0},
+		{"Unknown field", `size > 1GiB && color == "red"`, 16},
+		{"Type mismatch", `size > "big"`, 6},
+		{"Invalid size", `size > 10x`, 8},
+		{"Invalid duration", `atime < now - 10x`, 15},
+		{"Not boolean", `size + 1`, 6},
+		{"Missing value", `size > && duplicate`, 8},
+		{"Unclosed parenthesis", `(size > 1GiB`, 13},
+		{"Unterminated string", `name == "foo`, 9},
+		{"Unexpected character", `size > 1GiB & duplicate`, 13},
+		{"Non-ASCII identifier", `size > 1GiB && café == "yes"`, 16},
+		{"Non-ASCII quantity", `size > 1é`, 8},
+		{"Non-ASCII digits", `size > ١٢`, 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			_, err := ParseExpression(tc.Source)
+			assertError(t, err, tc.ErrorColumn != 0)
+			if err == nil {
+				return
+			}
+
+			expressionErr, ok := err.(*ExpressionError)
+			if !ok {
+				t.Fatalf("unexpected error type: %v", err)
+			}
+			if expressionErr.Column != tc.ErrorColumn {
+				t.Errorf("column %d != %d\n%v", expressionErr.Column, tc.ErrorColumn, err)
+			}
+		})
+	}
+}
+
+func TestExpressionFilter(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "a"},
+		{"letters/upper/A.txt", "AAAA"},
+		{"numbers/1.txt", "11"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes("letters/a.txt", time.Now().Add(-time.Hour * 48), time.Now())
+	os.Chmod("numbers/1.txt", 0600)
+
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	(*pathsToTest)[2].Metadata.Duplicate = true
+
+	testCases := []struct {
+		Source string
+		ExpectedPaths []string
+	}{
+		{`size >= 2`, []string{"letters/upper/A.txt", "numbers/1.txt"}},
+		{`atime < now - 1d`, []string{"letters/a.txt"}},
+		{`depth == 3`, []string{"letters/upper/A.txt"}},
+		{`name in ["a.txt", "1.txt"] && ext == "txt"`, []string{"letters/a.txt", "numbers/1.txt"}},
+		{`path ~ "**/letters/*" || mode == 0600`, []string{"letters/a.txt", "numbers/1.txt"}},
+		{`!duplicate && size < 1KiB`, []string{"letters/a.txt", "letters/upper/A.txt"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			expression, err := ParseExpression(tc.Source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filteredPaths := expression.Filter(*pathsToTest, tempPath)
+			assertPathsEqual(t, filteredPaths, tc.ExpectedPaths, filepath.Clean(tempPath))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// HashXattr is the extended attribute used to cache the hash of a file.
+const HashXattr string = "user.reddup.hash"
+
+// hashStamp is the state of a file when it was hashed. A cached hash is only
+// valid if the file is still in the same state. The ctime isn't part of the
+// stamp because renaming a file or storing the hash changes it.
+type hashStamp struct {
+	Algorithm HashAlgorithm
+	Size int64
+	ModTime int64
+}
+
+// newHashStamp returns the stamp of the file described by info for a hash
+// computed using algorithm.
+func newHashStamp(algorithm HashAlgorithm, info os.FileInfo) hashStamp {
+	return hashStamp{Algorithm: algorithm, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+}
+
+// formatHash returns the value of the HashXattr extended attribute for sum
+// with stamp.
+func formatHash(stamp hashStamp, sum []byte) string {
+	return fmt.Sprintf("%s %d %d %x", stamp.Algorithm, stamp.Size, stamp.ModTime, sum)
+}
+
+// parseHash parses a value of the HashXattr extended attribute and returns the
+// stamp and hash in it. Values which don't have exactly four fields, like
+// those stored by older versions, are invalid.
+func parseHash(value string) (stamp hashStamp, sum []byte, err error) {
+	if len(strings.Fields(value)) != 4 {
+		return stamp, nil, fmt.Errorf("invalid cached hash: %q", value)
+	}
+	var hexSum string
+	_, err = fmt.Sscanf(value, "%s %d %d %s", &stamp.Algorithm, &stamp.Size, &stamp.ModTime, &hexSum)
+	if err != nil {
+		return stamp, nil, err
+	}
+	sum, err = hex.DecodeString(hexSum)
+	return stamp, sum, err
+}
+
+// cachedChecksum is like checksum, but it reuses the hash stored in the
+// HashXattr extended attribute of the file if the size and mtime of the file
+// haven't changed since it was stored. Otherwise, the file is hashed and the
+// hash is stored. If the hash can't be stored, it is still returned.
+func cachedChecksum(path string, algorithm HashAlgorithm, ioLimit chan struct{}) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	stamp := newHashStamp(algorithm, info)
+
+	if value, err := getXattr(path, HashXattr); err == nil {
+		if cachedStamp, sum, err := parseHash(string(value)); err == nil && cachedStamp == stamp {
+			return sum, nil
+		}
+	}
+
+	sum, err := checksum(path, algorithm, ioLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only store the hash if the file didn't change while it was being hashed.
+	newInfo, err := os.Stat(path)
+	if err == nil && newHashStamp(algorithm, newInfo) == stamp {
+		setXattr(path, HashXattr, []byte(formatHash(stamp, sum)))
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"testing"
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+func TestCachedChecksum(t *testing.T) {
+	_, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	err := writeFiles(fileContents{{"letters/a.txt", "aaa"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setXattr("letters/a.txt", "user.reddup.test", []byte("1")); err == errXattrUnsupported {
+		t.Skip("extended attributes are not supported")
+	}
+
+	ioLimit := make(chan struct{}, 1)
+	sum, err := cachedChecksum("letters/a.txt", HashSHA256, ioLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSum, _ := checksum("letters/a.txt", HashSHA256, ioLimit)
+	if !bytes.Equal(sum, expectedSum) {
+		t.Errorf("%x != %x", sum, expectedSum)
+	}
+	if _, err := getXattr("letters/a.txt", HashXattr); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stored hash is reused while the file is unchanged, even though storing
+	// it changes the ctime.
+	info, _ := os.Stat("letters/a.txt")
+	fakeSum := []byte("fake")
+	setXattr("letters/a.txt", HashXattr, []byte(formatHash(newHashStamp(HashSHA256, info), fakeSum)))
+	sum, err = cachedChecksum("letters/a.txt", HashSHA256, ioLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sum, fakeSum) {
+		t.Errorf("%x != %x", sum, fakeSum)
+	}
+
+	// The stored hash survives a rename, which changes the ctime.
+	time.Sleep(10 * time.Millisecond)
+	if err := os.Rename("letters/a.txt", "letters/renamed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	sum, err = cachedChecksum("letters/renamed.txt", HashSHA256, ioLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sum, fakeSum) {
+		t.Errorf("%x != %x", sum, fakeSum)
+	}
+	if err := os.Rename("letters/renamed.txt", "letters/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the file invalidates the stored hash.
+	err = writeFiles(fileContents{{"letters/a.txt", "bbbb"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err = cachedChecksum("letters/a.txt", HashSHA256, ioLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSum, _ = checksum("letters/a.txt", HashSHA256, ioLimit)
+	if !bytes.Equal(sum, expectedSum) {
+		t.Errorf("%x != %x", sum, expectedSum)
+	}
+}
+
+func TestCachedChecksumOldFormat(t *testing.T) {
+	_, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	err := writeFiles(fileContents{{"letters/a.txt", "aaa"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setXattr("letters/a.txt", "user.reddup.test", []byte("1")); err == errXattrUnsupported {
+		t.Skip("extended attributes are not supported")
+	}
+
+	// A hash stored with a ctime by an older version is never reused, even if
+	// the ctime could be misread as the hash.
+	info, _ := os.Stat("letters/a.txt")
+	stamp := newHashStamp(HashSHA256, info)
+	value := fmt.Sprintf("%s %d %d %d %x", stamp.Algorithm, stamp.Size, stamp.ModTime, 1234, []byte("fake"))
+	setXattr("letters/a.txt", HashXattr, []byte(value))
+
+	ioLimit := make(chan struct{}, 1)
+	sum, err := cachedChecksum("letters/a.txt", HashSHA256, ioLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSum, _ := checksum("letters/a.txt", HashSHA256, ioLimit)
+	if !bytes.Equal(sum, expectedSum) {
+		t.Errorf("%x != %x", sum, expectedSum)
+	}
+
+	newValue, err := getXattr("letters/a.txt", HashXattr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, storedSum, err := parseHash(string(newValue)); err != nil || !bytes.Equal(storedSum, expectedSum) {
+		t.Errorf("the hash is not stored again: %s", newValue)
+	}
+}
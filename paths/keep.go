@@ -0,0 +1,165 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"path/filepath"
+)
+
+// KeepRule decides which of two identical files should be kept. It returns a
+// negative number if a should be kept over b, a positive number if b should be
+// kept over a and zero if the rule doesn't prefer either.
+type KeepRule func(a, b FilePath) int
+
+// KeepNewest prefers the file with the most recent mtime.
+func KeepNewest(a, b FilePath) int {
+	return compareInts(b.Stat.ModTime().UnixNano(), a.Stat.ModTime().UnixNano())
+}
+
+// KeepOldest prefers the file with the least recent mtime.
+func KeepOldest(a, b FilePath) int {
+	return -KeepNewest(a, b)
+}
+
+// KeepShortestPath prefers the file with the shortest path.
+func KeepShortestPath(a, b FilePath) int {
+	return compareInts(int64(len(a.Path)), int64(len(b.Path)))
+}
+
+// KeepLongestPath prefers the file with the longest path.
+func KeepLongestPath(a, b FilePath) int {
+	return -KeepShortestPath(a, b)
+}
+
+// KeepMostLinks prefers the file with the most hard links.
+func KeepMostLinks(a, b FilePath) int {
+	return compareInts(int64(getLinkCount(b.Stat)), int64(getLinkCount(a.Stat)))
+}
+
+// KeepUnder returns a rule that prefers files under the earliest directory in
+// dirs. Files which aren't under any of the directories are kept last.
+func KeepUnder(dirs []string) KeepRule {
+	absDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if absDir, err := filepath.Abs(dir); err == nil {
+			absDirs = append(absDirs, absDir)
+		}
+	}
+
+	dirIndex := func(path string) int64 {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return int64(len(absDirs))
+		}
+		for i, dir := range absDirs {
+			if absPath == dir || strings.HasPrefix(absPath, dir + string(filepath.Separator)) {
+				return int64(i)
+			}
+		}
+		return int64(len(absDirs))
+	}
+
+	return func(a, b FilePath) int {
+		return compareInts(dirIndex(a.Path), dirIndex(b.Path))
+	}
+}
+
+// KeepProtected returns a rule that prefers files in protected, such as files
+// which are excluded or pinned and can't be cleaned up.
+func KeepProtected(protected FilePaths) KeepRule {
+	isProtected := make(map[string]bool)
+	for _, path := range protected {
+		isProtected[path.Path] = true
+	}
+	protectedIndex := func(path string) int64 {
+		if isProtected[path] {
+			return 0
+		}
+		return 1
+	}
+
+	return func(a, b FilePath) int {
+		return compareInts(protectedIndex(a.Path), protectedIndex(b.Path))
+	}
+}
+
+// ParseKeepRule returns the rule described by spec. This accepts "newest,"
+// "oldest," "shortest-path," "longest-path," "most-links" and "under:"
+// followed by a list of directories separated by the OS path list separator.
+func ParseKeepRule(spec string) (KeepRule, error) {
+	switch spec {
+	case "newest":
+		return KeepNewest, nil
+	case "oldest":
+		return KeepOldest, nil
+	case "shortest-path":
+		return KeepShortestPath, nil
+	case "longest-path":
+		return KeepLongestPath, nil
+	case "most-links":
+		return KeepMostLinks, nil
+	}
+
+	if strings.HasPrefix(spec, "under:") {
+		dirs := filepath.SplitList(strings.TrimPrefix(spec, "under:"))
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("the rule '%s' must include at least one directory", spec)
+		}
+		return KeepUnder(dirs), nil
+	}
+
+	return nil, fmt.Errorf("the string '%s' is not a valid rule for keeping duplicates", spec)
+}
+
+// KeepDuplicates returns all duplicate files in groups as a single slice, but
+// omits the file in each group that should be kept according to rules. Each
+// rule is only used to break ties left by the rules before it, and remaining
+// ties are broken by path. Each group is sorted in place so that the file
+// which is kept comes first, and every other file in the group has its
+// Metadata.Kept field set to the path of that file.
+func KeepDuplicates(groups []FilePaths, rules []KeepRule) (duplicates FilePaths) {
+	for _, group := range groups {
+		sortByRules(group, rules)
+
+		for i := range group[1:] {
+			group[i + 1].Metadata.Kept = group[0].Path
+		}
+		duplicates = append(duplicates, group[1:]...)
+	}
+
+	return duplicates
+}
+
+// sortByRules sorts group in place so that the files which should be kept
+// according to rules come first. Ties are broken by path.
+func sortByRules(group FilePaths, rules []KeepRule) {
+	sort.Slice(group, func(i, j int) bool {
+		for _, rule := range rules {
+			if result := rule(group[i], group[j]); result != 0 {
+				return result < 0
+			}
+		}
+		return group[i].Path < group[j].Path
+	})
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"time"
+	"path/filepath"
+)
+
+func TestKeepDuplicates(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "aaa"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Give every file the same mtime except 1.txt, which is the oldest.
+	now := time.Now()
+	os.Chtimes("letters/a.txt", now, now)
+	os.Chtimes("letters/upper/A.txt", now, now)
+	os.Chtimes("numbers/1.txt", now, now.Add(-time.Hour))
+
+	// Give a.txt an extra hard link.
+	err = os.Link("letters/a.txt", "a-link.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		TestName string
+		Specs []string
+		ExpectedKept string
+	}{
+		{"Newest", []string{"newest"}, "letters/a.txt"},
+		{"Oldest", []string{"oldest"}, "numbers/1.txt"},
+		{"Shortest path", []string{"shortest-path"}, "letters/a.txt"},
+		{"Longest path", []string{"longest-path"}, "letters/upper/A.txt"},
+		{"Most links", []string{"most-links"}, "letters/a.txt"},
+		{"Under", []string{"under:" + filepath.Join(tempPath, "numbers")}, "numbers/1.txt"},
+		{"Tie-breaker", []string{"newest", "longest-path"}, "letters/upper/A.txt"},
+		{
+			"Under priority",
+			[]string{"under:" + filepath.Join(tempPath, "foo") + string(filepath.ListSeparator) + filepath.Join(tempPath, "letters"), "longest-path"},
+			"letters/upper/A.txt",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			var rules []KeepRule
+			for _, spec := range tc.Specs {
+				rule, err := ParseKeepRule(spec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, rule)
+			}
+
+			group, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			duplicates := KeepDuplicates([]FilePaths{*group}, rules)
+
+			expectedKept := filepath.Join(tempPath, tc.ExpectedKept)
+			if (*group)[0].Path != expectedKept {
+				t.Errorf("%v != %v", (*group)[0].Path, expectedKept)
+			}
+			for _, duplicate := range duplicates {
+				if duplicate.Metadata.Kept != expectedKept {
+					t.Errorf("Kept: %v", duplicate.Metadata.Kept)
+				}
+			}
+		})
+	}
+}
+
+func TestParseKeepRule(t *testing.T) {
+	testCases := []struct {
+		Spec string
+		ErrorExpected bool
+	}{
+		{"newest", false},
+		{"under:/foo", false},
+		{"under:", true},
+		{"largest", true},
+		{"not-excluded", true},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseKeepRule(tc.Spec)
+		assertError(t, err, tc.ErrorExpected)
+	}
+}
+
+func TestKeepProtected(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	group, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := FilePaths{(*group)[2]}
+
+	// The protected copy is kept even though another rule prefers a different
+	// copy.
+	KeepDuplicates([]FilePaths{*group}, []KeepRule{KeepProtected(protected), KeepShortestPath})
+	if (*group)[0].Path != protected[0].Path {
+		t.Errorf("%v != %v", (*group)[0].Path, protected[0].Path)
+	}
+}
@@ -20,9 +20,11 @@ along with reddup.  If not, see <http://www.gnu.org/licenses/>.
 package paths
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"io"
+	"strings"
 )
 
 const newDirPerm os.FileMode = 0700
@@ -61,9 +63,103 @@ func moveFile(srcPath, destPath string) (err error) {
 	return nil
 }
 
+// moveDir moves the files in the directory srcPath that were found when it was
+// scanned, which are given by its Metadata.Contents field, to destPath. The
+// files may only be regular files. Files which were added to the directory
+// since then are left in place along with the directories that contain them,
+// and the directories which are left empty are removed. If a file in destPath
+// already exists, an error is returned.
+func moveDir(srcPath FilePath, destPath string) (err error) {
+	if srcPath.Metadata.Contents == nil {
+		return fmt.Errorf("the contents of the directory '%s' are not known", srcPath.Path)
+	}
+	for _, contentPath := range srcPath.Metadata.Contents {
+		if !isUnderDir(contentPath.Path, srcPath.Path) {
+			return fmt.Errorf("the file '%s' is not in the directory '%s'", contentPath.Path, srcPath.Path)
+		}
+		if !contentPath.Stat.Mode().IsRegular() {
+			return fmt.Errorf("the file '%s' is not a regular file or a directory", contentPath.Path)
+		}
+	}
+
+	var dirs []string
+	err = filepath.Walk(srcPath.Path, func(walkPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, walkPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := moveUnit(srcPath.Path, srcPath.Metadata.Contents, destPath); err != nil {
+		return err
+	}
+
+	// Remove the empty directories, starting with the deepest ones, and create
+	// them in destPath so that empty directories are moved too.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil {
+			continue
+		}
+		relPath, err := filepath.Rel(srcPath.Path, dirs[i])
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(destPath, relPath), newDirPerm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveUnit moves each file in srcPaths to the path with the same path relative
+// to srcDir in destDir. Either every file is moved or none of them are. If a
+// file in destDir already exists, an error is returned. If a file can't be
+// moved back after another file fails to move, the error says so.
+func moveUnit(srcDir string, srcPaths FilePaths, destDir string) (err error) {
+	destPaths := make([]string, len(srcPaths))
+	for i, srcPath := range srcPaths {
+		relPath, err := filepath.Rel(srcDir, srcPath.Path)
+		if err != nil {
+			return err
+		}
+		destPaths[i] = filepath.Join(destDir, relPath)
+		if _, err := os.Lstat(destPaths[i]); err == nil {
+			return fmt.Errorf("the file '%s' already exists", destPaths[i])
+		}
+	}
+
+	for i, srcPath := range srcPaths {
+		if err := moveFile(srcPath.Path, destPaths[i]); err != nil {
+			// Move back the files which were already moved.
+			var notRestored []string
+			for j := i - 1; j >= 0; j-- {
+				if restoreErr := moveFile(destPaths[j], srcPaths[j].Path); restoreErr != nil {
+					notRestored = append(notRestored, restoreErr.Error())
+				}
+			}
+			if len(notRestored) > 0 {
+				return fmt.Errorf(
+					"%v, and these files could not be moved back: %s", err, strings.Join(notRestored, "; "))
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MoveStructuredFiles moves the files srcPaths to the directory at destPath
 // and preserves their original file structure relative to srcDir. File mtimes
-// and permissions are preserved. If a file in destDir already exists, an error
+// and permissions are preserved. Directories are moved along with the files
+// that were found in them when they were scanned, and companion files are
+// moved together or not at all. If a file in destDir already exists, an error
 // is returned.
 func MoveStructuredFiles(srcDir string, srcPaths FilePaths, destDir string) (err error) {
 	for _, srcPath := range srcPaths {
@@ -73,7 +169,13 @@ func MoveStructuredFiles(srcDir string, srcPaths FilePaths, destDir string) (err
 		}
 
 		destPath := filepath.Join(destDir, relPath)
-		err = moveFile(srcPath.Path, destPath)
+		if srcPath.Stat.IsDir() {
+			err = moveDir(srcPath, destPath)
+		} else if srcPath.Metadata.Contents != nil {
+			err = moveUnit(srcDir, srcPath.Metadata.Contents, destDir)
+		} else {
+			err = moveFile(srcPath.Path, destPath)
+		}
 		if err != nil {
 			return err
 		}
@@ -82,4 +82,113 @@ func TestMoveStructuredFiles(t *testing.T) {
 		err = MoveStructuredFiles(srcPath, *pathsToTest, destPath)
 		assertError(t, err, true)
 	})
+
+	t.Run("Companions", func(t *testing.T) {
+		srcPath, teardownFunc := setupFiles(t)
+		defer teardownFunc()
+
+		destPath, teardownFunc := setupTempDir(t)
+		defer teardownFunc()
+
+		pathsToTest, err := NewFilePathsFromRel([]string{"letters/a.txt", "numbers/1.txt"}, srcPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := (*pathsToTest)[0]
+		unit.Metadata.Contents = *pathsToTest
+
+		// Neither file is moved if one of them already exists in the
+		// destination directory.
+		os.MkdirAll(filepath.Join(destPath, "numbers"), newDirPerm)
+		file, err := os.Create(filepath.Join(destPath, "numbers/1.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+
+		err = MoveStructuredFiles(srcPath, FilePaths{unit}, destPath)
+		assertError(t, err, true)
+		if _, err := os.Stat(filepath.Join(srcPath, "letters/a.txt")); os.IsNotExist(err) {
+			t.Error("File missing from source directory: letters/a.txt")
+		}
+
+		os.Remove(filepath.Join(destPath, "numbers/1.txt"))
+		err = MoveStructuredFiles(srcPath, FilePaths{unit}, destPath)
+		assertError(t, err, false)
+		for _, filePath := range []string{"letters/a.txt", "numbers/1.txt"} {
+			if _, err := os.Stat(filepath.Join(destPath, filePath)); os.IsNotExist(err) {
+				t.Error(fmt.Sprintf("File missing from destination directory: %v", filePath))
+			}
+		}
+	})
+
+	t.Run("Directories", func(t *testing.T) {
+		expectedPaths := []string{"letters/a.txt", "letters/upper/A.txt"}
+		srcPath, teardownFunc := setupFiles(t)
+		defer teardownFunc()
+
+		destPath, teardownFunc := setupTempDir(t)
+		defer teardownFunc()
+
+		pathsToTest, err := NewFilePathsFromRel([]string{"letters"}, srcPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := ScanTree(filepath.Join(srcPath, "letters"), ModeFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		(*pathsToTest)[0].Metadata.Contents = contents
+
+		err = MoveStructuredFiles(srcPath, *pathsToTest, destPath)
+		assertError(t, err, false)
+
+		if _, err := os.Stat(filepath.Join(srcPath, "letters")); !os.IsNotExist(err) {
+			t.Error("Directory exists in source directory: letters")
+		}
+		for _, filePath := range expectedPaths {
+			if _, err := os.Stat(filepath.Join(destPath, filePath)); os.IsNotExist(err) {
+				t.Error(fmt.Sprintf("File missing from destination directory: %v", filePath))
+			}
+		}
+	})
+
+	t.Run("Files added to directory", func(t *testing.T) {
+		srcPath, teardownFunc := setupFiles(t)
+		defer teardownFunc()
+
+		destPath, teardownFunc := setupTempDir(t)
+		defer teardownFunc()
+
+		pathsToTest, err := NewFilePathsFromRel([]string{"letters"}, srcPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := ScanTree(filepath.Join(srcPath, "letters"), ModeFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		(*pathsToTest)[0].Metadata.Contents = contents
+
+		// A file which is created after the directory was scanned is not moved.
+		err = writeFiles(fileContents{{"letters/upper/B.txt", "new"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = MoveStructuredFiles(srcPath, *pathsToTest, destPath)
+		assertError(t, err, false)
+
+		if _, err := os.Stat(filepath.Join(srcPath, "letters/upper/B.txt")); os.IsNotExist(err) {
+			t.Error("File missing from source directory: letters/upper/B.txt")
+		}
+		if _, err := os.Stat(filepath.Join(destPath, "letters/upper/B.txt")); !os.IsNotExist(err) {
+			t.Error("File exists in destination directory: letters/upper/B.txt")
+		}
+		for _, filePath := range []string{"letters/a.txt", "letters/upper/A.txt"} {
+			if _, err := os.Stat(filepath.Join(destPath, filePath)); os.IsNotExist(err) {
+				t.Error(fmt.Sprintf("File missing from destination directory: %v", filePath))
+			}
+		}
+	})
 }
@@ -38,7 +38,11 @@ type FilePath struct {
 	Stat os.FileInfo
 	Metadata struct {
 		Duplicate bool
+		Similar bool
+		BackedUp bool
+		Kept string
 		Rank int
+		Contents FilePaths
 	}
 }
 
@@ -52,6 +56,36 @@ func NewFilePath(path string) (*FilePath, error) {
 	return &FilePath{Path: path, Time: timeInfo, Stat: info}, nil
 }
 
+// Size returns the size of the file in bytes. If the file is a unit of several
+// files, such as a redundant directory or a file with companions, this is the
+// total size of Metadata.Contents.
+func (f FilePath) Size() int64 {
+	if f.Metadata.Contents == nil {
+		return f.Stat.Size()
+	}
+
+	var total int64
+	for _, path := range f.Metadata.Contents {
+		total += path.Stat.Size()
+	}
+	return total
+}
+
+// AllocatedSize returns the number of bytes allocated on disk for the file.
+// If the file is a unit of several files, this is the total for
+// Metadata.Contents.
+func (f FilePath) AllocatedSize() int64 {
+	if f.Metadata.Contents == nil {
+		return getAllocatedSize(f.Stat)
+	}
+
+	var total int64
+	for _, path := range f.Metadata.Contents {
+		total += getAllocatedSize(path.Stat)
+	}
+	return total
+}
+
 // String returns the default string representation of the type.
 func (f FilePath) String() string {
 	return fmt.Sprintf("\"%v\"", f.Path)
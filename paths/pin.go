@@ -0,0 +1,223 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"errors"
+	"os"
+	"bufio"
+	"sort"
+	"path/filepath"
+	"io/ioutil"
+	"strings"
+)
+
+// PinXattr is the extended attribute used to mark a file as pinned.
+const PinXattr string = "user.reddup.pin"
+
+// errNoPinDatabase is returned when pins must be stored in the database file,
+// but no path was given for it.
+var errNoPinDatabase = errors.New("extended attributes are not supported, and there is no file to store pins in")
+
+// Pins keeps track of which files are pinned so that they are never suggested
+// to be cleaned up. Files are pinned by setting an extended attribute on them.
+// If the file system doesn't support extended attributes, their absolute path
+// is stored in a database file instead.
+type Pins struct {
+	DatabasePath string
+	database map[string]struct{}
+	cache map[string]bool
+}
+
+// NewPins creates a new Pins struct which uses the database file at
+// databasePath. The file doesn't need to exist.
+func NewPins(databasePath string) (*Pins, error) {
+	pins := &Pins {
+		DatabasePath: databasePath,
+		database: make(map[string]struct{}),
+		cache: make(map[string]bool),
+	}
+
+	file, err := os.Open(databasePath)
+	if os.IsNotExist(err) {
+		return pins, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			pins.database[line] = struct{}{}
+		}
+	}
+
+	return pins, scanner.Err()
+}
+
+// Pin pins the file or directory at path.
+func (p *Pins) Pin(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return err
+	}
+
+	err = setXattr(absPath, PinXattr, []byte("1"))
+	if err == errXattrUnsupported {
+		p.database[absPath] = struct{}{}
+		err = p.save()
+	}
+	p.cache = make(map[string]bool)
+
+	return err
+}
+
+// Unpin unpins the file or directory at path. It does not unpin files under
+// a pinned directory.
+func (p *Pins) Unpin(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	err = removeXattr(absPath, PinXattr)
+	if err != nil && err != errNoXattr && err != errXattrUnsupported && !os.IsNotExist(err) {
+		return err
+	}
+	if _, ok := p.database[absPath]; ok {
+		delete(p.database, absPath)
+		if err := p.save(); err != nil {
+			return err
+		}
+	}
+	p.cache = make(map[string]bool)
+
+	return nil
+}
+
+// IsPinned returns true if the file or directory at path is pinned itself.
+func (p *Pins) IsPinned(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	if pinned, ok := p.cache[absPath]; ok {
+		return pinned
+	}
+
+	_, pinned := p.database[absPath]
+	if !pinned {
+		_, err := getXattr(absPath, PinXattr)
+		pinned = err == nil
+	}
+	p.cache[absPath] = pinned
+
+	return pinned
+}
+
+// CheckMatch returns true if the given file path is pinned or is under a
+// pinned directory. Otherwise, it returns false.
+func (p *Pins) CheckMatch(checkPath string) (matched bool) {
+	_, matched = p.MatchingPin(checkPath)
+	return matched
+}
+
+// MatchingPin returns the pinned path that the given file path is equal to or
+// under. If the file path is not pinned, matched is false.
+func (p *Pins) MatchingPin(checkPath string) (pinnedPath string, matched bool) {
+	absPath, err := filepath.Abs(checkPath)
+	if err != nil {
+		return "", false
+	}
+
+	for {
+		if p.IsPinned(absPath) {
+			return absPath, true
+		}
+
+		parentPath := filepath.Dir(absPath)
+		if parentPath == absPath {
+			return "", false
+		}
+		absPath = parentPath
+	}
+}
+
+// Find returns all the pinned files and directories in the tree rooted at
+// rootPath, including rootPath itself.
+func (p *Pins) Find(rootPath string) (FilePaths, error) {
+	root, err := NewFilePath(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	allPaths, err := ScanTree(rootPath, ModeFile | ModeDir)
+	if err != nil {
+		return nil, err
+	}
+	allPaths = append(FilePaths{*root}, allPaths...)
+
+	output := make(FilePaths, 0)
+	for _, path := range allPaths {
+		if p.IsPinned(path.Path) {
+			output = append(output, path)
+		}
+	}
+	sort.Sort(output)
+
+	return output, nil
+}
+
+// save writes the database of pinned paths to the database file.
+func (p *Pins) save() error {
+	var lines []string
+	for path := range p.database {
+		lines = append(lines, path + "\n")
+	}
+	sort.Strings(lines)
+
+	if p.DatabasePath == "" {
+		return errNoPinDatabase
+	}
+	if err := os.MkdirAll(filepath.Dir(p.DatabasePath), newDirPerm); err != nil {
+		return err
+	}
+	tempFile, err := ioutil.TempFile(filepath.Dir(p.DatabasePath), ".pins-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.WriteString(strings.Join(lines, "")); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), p.DatabasePath)
+}
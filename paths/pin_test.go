@@ -0,0 +1,118 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"path/filepath"
+)
+
+func TestPins(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	pins, err := NewPins(filepath.Join(tempPath, "pins"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pins.Pin(filepath.Join(tempPath, "letters/upper"))
+	assertError(t, err, false)
+	err = pins.Pin(filepath.Join(tempPath, "numbers/1.txt"))
+	assertError(t, err, false)
+
+	testCases := []struct {
+		CheckPath string
+		Matches bool
+	}{
+		{"letters/a.txt", false},
+		{"letters/upper", true},
+		{"letters/upper/A.txt", true},
+		{"numbers/1.txt", true},
+	}
+
+	for _, tc := range testCases {
+		if result := pins.CheckMatch(filepath.Join(tempPath, tc.CheckPath)); result != tc.Matches {
+			t.Errorf("Path: %v", tc.CheckPath)
+		}
+	}
+
+	pinnedPaths, err := pins.Find(tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPathsEqual(t, pinnedPaths, []string{"letters/upper", "numbers/1.txt"}, tempPath)
+
+	err = pins.Unpin(filepath.Join(tempPath, "letters/upper"))
+	assertError(t, err, false)
+	if pins.CheckMatch(filepath.Join(tempPath, "letters/upper/A.txt")) {
+		t.Error("file is still pinned after unpinning its parent")
+	}
+}
+
+func TestPinsDatabase(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	databasePath := filepath.Join(tempPath, "pins")
+	pins, err := NewPins(databasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Store the pin in the database as if extended attributes weren't
+	// supported.
+	pinnedPath := filepath.Join(tempPath, "letters")
+	pins.database[pinnedPath] = struct{}{}
+	err = pins.save()
+	assertError(t, err, false)
+
+	pins, err = NewPins(databasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pins.CheckMatch(filepath.Join(tempPath, "letters/a.txt")) {
+		t.Error("file under pinned directory is not pinned")
+	}
+
+	err = pins.Unpin(pinnedPath)
+	assertError(t, err, false)
+	if pins.IsPinned(pinnedPath) {
+		t.Error("directory is still pinned after unpinning it")
+	}
+}
+
+func TestPinsDatabaseErrors(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	// There is nowhere to save pins without a database path.
+	pins, err := NewPins("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins.database[filepath.Join(tempPath, "letters")] = struct{}{}
+	assertError(t, pins.save(), true)
+
+	// The directory for the database can't be created under a file.
+	pins.DatabasePath = filepath.Join(tempPath, "letters/a.txt/reddup/pins")
+	assertError(t, pins.save(), true)
+}
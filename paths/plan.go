@@ -0,0 +1,249 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PlanVersion is the version of the format of plan files.
+const PlanVersion = 1
+
+// PlanFile is a file in a plan along with the information needed to check
+// whether it changed after the plan was made.
+type PlanFile struct {
+	Path string `json:"path"`
+	Size int64 `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Inode uint64 `json:"inode"`
+	Hash string `json:"hash,omitempty"`
+}
+
+// PlanEntry is a file, directory or group of companion files in a plan which
+// is moved as a single unit. Files contains every file in the unit.
+type PlanEntry struct {
+	Path string `json:"path"`
+	IsDir bool `json:"is_dir,omitempty"`
+	Files []PlanFile `json:"files"`
+}
+
+// Plan is a list of files to be moved which can be reviewed before it is
+// applied.
+type Plan struct {
+	Version int `json:"version"`
+	Source string `json:"source"`
+	MaxSize int64 `json:"max_size"`
+	Created time.Time `json:"created"`
+	Entries []PlanEntry `json:"entries"`
+}
+
+// NewPlanFile returns the plan for the file at filePath. If algorithm is not
+// empty, the hash of the file is recorded too.
+func NewPlanFile(filePath FilePath, algorithm HashAlgorithm) (PlanFile, error) {
+	absPath, err := filepath.Abs(filePath.Path)
+	if err != nil {
+		return PlanFile{}, err
+	}
+	planFile := PlanFile {
+		Path: absPath,
+		Size: filePath.Stat.Size(),
+		ModTime: filePath.Stat.ModTime(),
+		Inode: getInode(filePath.Stat),
+	}
+	if algorithm != "" {
+		sum, err := checksum(absPath, algorithm, make(chan struct{}, 1))
+		if err != nil {
+			return PlanFile{}, err
+		}
+		planFile.Hash = fmt.Sprintf("%s:%s", algorithm, hex.EncodeToString(sum))
+	}
+
+	return planFile, nil
+}
+
+// Verify returns an error describing how the file changed since the plan was
+// made, or nil if it didn't. If checkHash is true, the contents of the file
+// are compared too.
+func (p PlanFile) Verify(checkHash bool) error {
+	info, err := os.Lstat(p.Path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", p.Path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s: no longer a regular file", p.Path)
+	}
+	if getInode(info) != p.Inode {
+		return fmt.Errorf("%s: replaced by another file", p.Path)
+	}
+	if info.Size() != p.Size {
+		return fmt.Errorf("%s: size changed from %d to %d bytes", p.Path, p.Size, info.Size())
+	}
+	if !info.ModTime().Equal(p.ModTime) {
+		return fmt.Errorf("%s: modified at %s", p.Path, info.ModTime().Format(time.RFC3339))
+	}
+
+	if checkHash && p.Hash != "" {
+		parts := strings.SplitN(p.Hash, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("%s: the hash '%s' is not valid", p.Path, p.Hash)
+		}
+		algorithm, err := ParseHashAlgorithm(parts[0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", p.Path, err)
+		}
+		sum, err := checksum(p.Path, algorithm, make(chan struct{}, 1))
+		if err != nil {
+			return fmt.Errorf("%s: %v", p.Path, err)
+		}
+		if hex.EncodeToString(sum) != parts[1] {
+			return fmt.Errorf("%s: contents changed", p.Path)
+		}
+	}
+
+	return nil
+}
+
+// NewPlanEntry returns the plan for filePath. Directories and groups of
+// companion files which are handled as single units include every file in
+// them. If algorithm is not empty, the hash of each file is recorded too.
+func NewPlanEntry(filePath FilePath, algorithm HashAlgorithm) (PlanEntry, error) {
+	absPath, err := filepath.Abs(filePath.Path)
+	if err != nil {
+		return PlanEntry{}, err
+	}
+	entry := PlanEntry{Path: absPath, IsDir: filePath.Stat.IsDir()}
+
+	files := filePath.Metadata.Contents
+	if files == nil {
+		files = FilePaths{filePath}
+	}
+	for _, file := range files {
+		planFile, err := NewPlanFile(file, algorithm)
+		if err != nil {
+			return PlanEntry{}, err
+		}
+		entry.Files = append(entry.Files, planFile)
+	}
+
+	return entry, nil
+}
+
+// Verify checks whether any file in the entry changed since the plan was made
+// like PlanFile.Verify. Directories must also contain the same files as they
+// did. The entry and every file in it must be inside the directory source. If
+// nothing changed, it returns the entry in a form which can be passed to
+// MoveStructuredFiles.
+func (e PlanEntry) Verify(source string, checkHash bool) (FilePath, error) {
+	if !inPlanSource(e.Path, source) {
+		return FilePath{}, fmt.Errorf("%s: not inside %s", e.Path, source)
+	}
+	for _, planFile := range e.Files {
+		if !inPlanSource(planFile.Path, source) || (e.IsDir && !isUnderDir(planFile.Path, e.Path)) {
+			return FilePath{}, fmt.Errorf("%s: not inside %s", planFile.Path, e.Path)
+		}
+		if err := planFile.Verify(checkHash); err != nil {
+			return FilePath{}, err
+		}
+	}
+
+	filePath, err := NewFilePath(e.Path)
+	if err != nil {
+		return FilePath{}, err
+	}
+	if filePath.Stat.IsDir() != e.IsDir {
+		return FilePath{}, fmt.Errorf("%s: replaced by another file", e.Path)
+	}
+	if !e.IsDir && len(e.Files) == 1 {
+		return *filePath, nil
+	}
+
+	planPaths := make([]string, len(e.Files))
+	for i, planFile := range e.Files {
+		planPaths[i] = planFile.Path
+	}
+	contents, err := NewFilePaths(planPaths)
+	if err != nil {
+		return FilePath{}, err
+	}
+	if e.IsDir {
+		currentPaths, err := ScanTree(e.Path, ModeFile | ModeLink)
+		if err != nil {
+			return FilePath{}, err
+		}
+		if !currentPaths.Equals(*contents) {
+			return FilePath{}, fmt.Errorf("%s: files were added to the directory", e.Path)
+		}
+	}
+	filePath.Metadata.Contents = *contents
+
+	return *filePath, nil
+}
+
+// inPlanSource returns true if path is an absolute path to a file which is
+// inside the directory source, even after symbolic links are resolved.
+func inPlanSource(path, source string) bool {
+	source = filepath.Clean(source)
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == source || !isUnderDir(path, source) {
+		return false
+	}
+
+	resolvedSource, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return false
+	}
+	resolvedDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	return isUnderDir(resolvedDir, resolvedSource)
+}
+
+// ReadPlan reads the plan in the file at path.
+func ReadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("the plan '%s' is not valid: %v", path, err)
+	}
+	if plan.Version != PlanVersion {
+		return nil, fmt.Errorf("the plan '%s' has the unsupported version %d", path, plan.Version)
+	}
+
+	return &plan, nil
+}
+
+// Write writes the plan to output.
+func (p Plan) Write(output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanEntryVerify(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "AAA"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filePath, err := NewFilePath(filepath.Join(tempPath, "letters/a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := NewPlanEntry(*filePath, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirPath, err := NewFilePath(filepath.Join(tempPath, "letters/upper"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirPath.Metadata.Contents, err = ScanTree(dirPath.Path, ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirEntry, err := NewPlanEntry(*dirPath, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		_, err := entry.Verify(tempPath, true)
+		assertError(t, err, false)
+		verifiedDir, err := dirEntry.Verify(tempPath, true)
+		assertError(t, err, false)
+		if len(verifiedDir.Metadata.Contents) != 1 {
+			t.Fatalf("expected 1 file in the directory, got %d", len(verifiedDir.Metadata.Contents))
+		}
+	})
+
+	t.Run("Outside source", func(t *testing.T) {
+		_, err := entry.Verify(filepath.Join(tempPath, "numbers"), false)
+		assertError(t, err, true)
+		_, err = dirEntry.Verify(dirPath.Path, false)
+		assertError(t, err, true)
+
+		escapingEntry := entry
+		escapingEntry.Path = filepath.Join(tempPath, "numbers/../letters/a.txt")
+		_, err = escapingEntry.Verify(filepath.Join(tempPath, "numbers"), false)
+		assertError(t, err, true)
+
+		// Every file in the entry must be inside the source too, and every
+		// file in a directory must be inside the directory.
+		outsideEntry := entry
+		outsideEntry.Files = append([]PlanFile{}, entry.Files...)
+		outsideEntry.Files = append(outsideEntry.Files, PlanFile{Path: filepath.Join(tempPath, "numbers/1.txt")})
+		_, err = outsideEntry.Verify(filepath.Join(tempPath, "letters"), false)
+		assertError(t, err, true)
+
+		outsideDirEntry := dirEntry
+		outsideDirEntry.Files = append([]PlanFile{}, dirEntry.Files...)
+		outsideDirEntry.Files = append(outsideDirEntry.Files, entry.Files...)
+		_, err = outsideDirEntry.Verify(tempPath, false)
+		assertError(t, err, true)
+	})
+
+	t.Run("Contents changed", func(t *testing.T) {
+		// Keep the size and mtime the same so that only the hash differs.
+		err := ioutil.WriteFile(filePath.Path, []byte("bbb"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(filePath.Path, filePath.Stat.ModTime(), filePath.Stat.ModTime())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = entry.Verify(tempPath, false)
+		assertError(t, err, false)
+		_, err = entry.Verify(tempPath, true)
+		assertError(t, err, true)
+	})
+
+	t.Run("Size changed", func(t *testing.T) {
+		err := ioutil.WriteFile(filePath.Path, []byte("aaaa"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = entry.Verify(tempPath, false)
+		assertError(t, err, true)
+	})
+
+	t.Run("File added to directory", func(t *testing.T) {
+		err := ioutil.WriteFile(filepath.Join(dirPath.Path, "B.txt"), []byte("BBB"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = dirEntry.Verify(tempPath, false)
+		assertError(t, err, true)
+	})
+}
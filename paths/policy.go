@@ -0,0 +1,191 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/lostatc/reddup/parse"
+)
+
+// These are the actions that a policy rule can take on the files it matches.
+const (
+	ActionList string = "list"
+	ActionMove string = "move"
+)
+
+// PolicyRule is a set of conditions that files must meet together with an
+// action to take on the files that meet them. Conditions which are left empty
+// match every file.
+type PolicyRule struct {
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+	MinSize string `toml:"min-size"`
+	MaxSize string `toml:"max-size"`
+	MinAge string `toml:"min-age"`
+	MaxAge string `toml:"max-age"`
+	Owner string `toml:"owner"`
+	Duplicate *bool `toml:"duplicate"`
+	Action string `toml:"action"`
+	Destination string `toml:"destination"`
+
+	minSize, maxSize int64
+	minAge, maxAge time.Duration
+}
+
+// Policy is an ordered list of rules. Each file is handled by the first rule
+// that it matches.
+type Policy struct {
+	Rules []PolicyRule `toml:"rule"`
+}
+
+// NewPolicyFromFile reads a policy from the TOML file at path.
+func NewPolicyFromFile(path string) (*Policy, error) {
+	policy := new(Policy)
+	if _, err := toml.DecodeFile(path, policy); err != nil {
+		return nil, err
+	}
+
+	for i := range policy.Rules {
+		if err := policy.Rules[i].parse(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i + 1, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// parse parses and validates the human-readable values in the rule.
+func (r *PolicyRule) parse() (err error) {
+	if r.Name == "" {
+		return fmt.Errorf("rules must have a name")
+	}
+
+	switch r.Action {
+	case ActionList:
+	case ActionMove:
+		if r.Destination == "" {
+			return fmt.Errorf("rules which move files must have a destination")
+		}
+	default:
+		return fmt.Errorf("the action '%s' is not valid", r.Action)
+	}
+
+	if r.MinSize != "" {
+		if r.minSize, err = parse.ReadFileSize(r.MinSize); err != nil {
+			return err
+		}
+	}
+	r.maxSize = -1
+	if r.MaxSize != "" {
+		if r.maxSize, err = parse.ReadFileSize(r.MaxSize); err != nil {
+			return err
+		}
+	}
+	if r.MinAge != "" {
+		if r.minAge, err = parse.ReadDuration(r.MinAge); err != nil {
+			return err
+		}
+	}
+	r.maxAge = -1
+	if r.MaxAge != "" {
+		if r.maxAge, err = parse.ReadDuration(r.MaxAge); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckMatch returns true if the given file meets every condition of the rule.
+// Paths are matched relative to startDir, and isDuplicate is whether the file
+// is a duplicate that would be suggested. The age of a file is the time since
+// it was last accessed.
+func (r *PolicyRule) CheckMatch(path FilePath, startDir string, isDuplicate bool) bool {
+	if r.Path != "" {
+		exclude := Exclude{Patterns: []string{r.Path}}
+		if !exclude.CheckMatch(path.Path, startDir) {
+			return false
+		}
+	}
+
+	size := path.Stat.Size()
+	if size < r.minSize || (r.maxSize >= 0 && size > r.maxSize) {
+		return false
+	}
+
+	age := time.Since(path.Time.AccessTime())
+	if age < r.minAge || (r.maxAge >= 0 && age > r.maxAge) {
+		return false
+	}
+
+	if r.Owner != "" && getOwner(path.Stat) != r.Owner {
+		return false
+	}
+
+	if r.Duplicate != nil && *r.Duplicate != isDuplicate {
+		return false
+	}
+
+	return true
+}
+
+// UsesDuplicates returns true if any rule in the policy depends on whether
+// files are duplicates.
+func (p *Policy) UsesDuplicates() bool {
+	for _, rule := range p.Rules {
+		if rule.Duplicate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan returns the files in paths that each rule in the policy matches. Paths
+// are matched relative to startDir, and duplicates are the files that would be
+// suggested as duplicates. The returned slice has one element per rule, and
+// each file is matched by at most one rule.
+func (p *Policy) Plan(paths FilePaths, startDir string, duplicates FilePaths) []FilePaths {
+	duplicateMap := make(map[string]struct{})
+	for _, path := range duplicates {
+		duplicateMap[path.Path] = struct{}{}
+	}
+
+	plan := make([]FilePaths, len(p.Rules))
+	for _, path := range paths {
+		_, isDuplicate := duplicateMap[path.Path]
+		if isDuplicate {
+			path.Metadata.Duplicate = true
+		}
+
+		for i := range p.Rules {
+			if p.Rules[i].CheckMatch(path, startDir, isDuplicate) {
+				plan[i] = append(plan[i], path)
+				break
+			}
+		}
+	}
+
+	return plan
+}
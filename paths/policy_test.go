@@ -0,0 +1,125 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const policyTestFileContents = `
+[[rule]]
+name = "Old duplicates"
+duplicate = true
+action = "move"
+destination = "/cold"
+
+[[rule]]
+name = "Large text files"
+path = "*.txt"
+min-size = "1KB"
+action = "move"
+destination = "/archive"
+
+[[rule]]
+name = "Old letters"
+path = "/letters"
+min-age = "1d"
+action = "list"
+`
+
+// writePolicy writes the contents of a policy to a temporary file.
+func writePolicy(t *testing.T, contents string) (path string, teardownFunc func()) {
+	tempFile, err := ioutil.TempFile("", "reddup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tempFile.Close()
+	tempFile.WriteString(contents)
+
+	return tempFile.Name(), func() {
+		os.Remove(tempFile.Name())
+	}
+}
+
+func TestNewPolicyFromFile(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Contents string
+		ErrorExpected bool
+	}{
+		{"Valid", policyTestFileContents, false},
+		{"No name", "[[rule]]\naction = \"list\"", true},
+		{"Invalid action", "[[rule]]\nname = \"foo\"\naction = \"delete\"", true},
+		{"No destination", "[[rule]]\nname = \"foo\"\naction = \"move\"", true},
+		{"Invalid size", "[[rule]]\nname = \"foo\"\naction = \"list\"\nmin-size = \"big\"", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			policyPath, teardownFunc := writePolicy(t, tc.Contents)
+			defer teardownFunc()
+
+			_, err := NewPolicyFromFile(policyPath)
+			assertError(t, err, tc.ErrorExpected)
+		})
+	}
+}
+
+func TestPolicyPlan(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	policyPath, teardownFunc := writePolicy(t, policyTestFileContents)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "a"},
+		{"letters/upper/A.txt", "A"},
+		{"numbers/1.txt", string(make([]byte, 2000))},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes("letters/a.txt", time.Now().Add(-time.Hour * 48), time.Now())
+
+	policy, err := NewPolicyFromFile(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicates, err := NewFilePathsFromRel([]string{"letters/upper/A.txt"}, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := policy.Plan(*pathsToTest, tempPath, *duplicates)
+
+	assertPathsEqual(t, plan[0], []string{"letters/upper/A.txt"}, tempPath)
+	assertPathsEqual(t, plan[1], []string{"numbers/1.txt"}, tempPath)
+	assertPathsEqual(t, plan[2], []string{"letters/a.txt"}, tempPath)
+}
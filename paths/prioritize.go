@@ -23,34 +23,73 @@ import (
 	"sort"
 	"math"
 	"time"
-	"os"
-	"crypto/sha256"
-	"io"
 )
 
-type SHA256Sum [32]byte
-
 type filePriority struct {
 	File FilePath
 	Priority float64
 }
 
-const BlockSize int = 4096
+// FilterStatus describes whether a file was selected to be cleaned up and, if
+// it wasn't, why.
+type FilterStatus int
+
+const (
+	StatusSelected FilterStatus = iota
+	StatusEmpty
+	StatusTooRecent
+	StatusOverBudget
+	StatusUnderQuota
+)
 
-// prioritizePaths sorts paths based on their size and atime. Paths with a
-// larger size and less recent atime are sorted first.
-func prioritize(paths FilePaths) (sorted FilePaths) {
+// String returns the default string representation of the type. This satisfies
+// the fmt.Stringer interface.
+func (s FilterStatus) String() string {
+	switch s {
+	case StatusSelected:
+		return "selected"
+	case StatusEmpty:
+		return "skipped because it is empty"
+	case StatusTooRecent:
+		return "skipped because it was accessed too recently"
+	case StatusOverBudget:
+		return "skipped because it doesn't fit in the remaining space"
+	case StatusUnderQuota:
+		return "skipped because its subtree is already under its quota"
+	default:
+		return "unknown"
+	}
+}
+
+// FilterDecision records how a file was treated when selecting files to be
+// cleaned up. Position is the 1-based position of the file when sorted by
+// priority, and Remaining is the number of bytes that were left to fill when
+// the file was considered.
+type FilterDecision struct {
+	Priority float64
+	Position int
+	Remaining int64
+	Status FilterStatus
+}
+
+// Priority returns the priority of a file based on its size and atime. Files
+// with a lower priority should be cleaned up first.
+func Priority(path FilePath) float64 {
+	size := path.Size()
+	if size == 0 {
+		return math.Inf(1)
+	}
+	return float64(path.Time.AccessTime().Unix() / size)
+}
+
+// prioritize sorts paths based on their size and atime and returns them with
+// the priority of each one. Paths with a larger size and less recent atime are
+// sorted first.
+func prioritize(paths FilePaths) []filePriority {
 	// Get a priority for each file path based on the size and atime.
 	priorities := make([]filePriority, 0)
 	for _, path := range paths {
-		size := path.Stat.Size()
-		var priority float64
-		if size == 0 {
-			priority = math.Inf(1)
-		} else {
-			priority = float64(path.Time.AccessTime().Unix() / size)
-		}
-		priorities = append(priorities, filePriority{File: path, Priority: priority})
+		priorities = append(priorities, filePriority{File: path, Priority: Priority(path)})
 	}
 
 	// Sort by path and then by priority so that the output for a given input
@@ -62,108 +101,49 @@ func prioritize(paths FilePaths) (sorted FilePaths) {
 		return priorities[i].Priority < priorities[j].Priority
 	})
 
-	for _, filePath := range priorities {
-		sorted = append(sorted, filePath.File)
-	}
-
-	return sorted
+	return priorities
 }
 
 // Filter returns the files with the largest size and most recent atime that
 // fit within totalSize and were last accessed at least minDuration in the past.
 func Filter(paths FilePaths, totalSize int64, minDuration time.Duration) FilePaths {
-	sortedPaths :=  prioritize(paths)
-	remainingSpace := int64(totalSize)
-	output := make(FilePaths, 0)
-	maxAtime := time.Now().Add(-minDuration)
-
-	for _, path := range sortedPaths {
-		if path.Stat.Size() == 0 || path.Time.AccessTime().After(maxAtime) {
-			continue
-		}
-
-		newRemainingSpace := remainingSpace - path.Stat.Size()
-		if newRemainingSpace >= 0 {
-			output = append(output, path)
-			remainingSpace = newRemainingSpace
-		}
-	}
-
+	output, _ := filter(paths, totalSize, minDuration)
 	return output
 }
 
-// checksum returns the SHA256 sum of a given file.
-func checksum(path string) (checksum SHA256Sum, err error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return checksum, err
-	}
-	defer file.Close()
-
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return checksum, err
-	}
-
-	// Convert the slice to a fixed-size array.
-	copy(checksum[:], hash.Sum(nil))
-	return checksum, nil
+// ExplainFilter returns a map of file paths to decisions that describe how
+// Filter treats each of the given files.
+func ExplainFilter(paths FilePaths, totalSize int64, minDuration time.Duration) map[string]FilterDecision {
+	_, decisions := filter(paths, totalSize, minDuration)
+	return decisions
 }
 
-// GetDuplicates determines which of the given files are identical and returns
-// them. Each FilePaths slice in the slice that is returned represents a group
-// of identical files. Files are compared first by size and then by checksum.
-func GetDuplicates(paths FilePaths) (duplicates []FilePaths) {
-	// Get the sizes of each file.
-	sizes := make(map[int64]FilePaths)
-	sameSizeFiles := make(FilePaths, 0)
-	for _, path := range paths {
-		sizes[path.Stat.Size()] = append(sizes[path.Stat.Size()], path)
-	}
-
-	// Find files with the same size.
-	for _, paths := range sizes {
-		if len(paths) > 1 {
-			sameSizeFiles = append(sameSizeFiles, paths...)
-		}
-	}
-
-	// Get the hashes of files with the same size.
-	hashes := make(map[SHA256Sum]FilePaths)
-	for _, path := range sameSizeFiles {
-		sum, err := checksum(path.Path)
-		if err != nil {
-			continue
-		}
-		hashes[sum] = append(hashes[sum], path)
-	}
+// filter is the implementation of Filter and ExplainFilter.
+func filter(paths FilePaths, totalSize int64, minDuration time.Duration) (FilePaths, map[string]FilterDecision) {
+	priorities := prioritize(paths)
+	remainingSpace := int64(totalSize)
+	output := make(FilePaths, 0)
+	decisions := make(map[string]FilterDecision)
+	maxAtime := time.Now().Add(-minDuration)
 
-	// Find files with the same hash.
-	for _, paths := range hashes {
-		if len(paths) > 1 {
-			duplicates = append(duplicates, paths)
-		}
-	}
+	for i, priority := range priorities {
+		path := priority.File
+		decision := FilterDecision{Priority: priority.Priority, Position: i + 1, Remaining: remainingSpace}
 
-	// Set a piece of metadata to differentiate these files as duplicates.
-	for i := range duplicates {
-		for j := range duplicates[i] {
-			duplicates[i][j].Metadata.Duplicate = true
+		if path.Size() == 0 {
+			decision.Status = StatusEmpty
+		} else if path.Time.AccessTime().After(maxAtime) {
+			decision.Status = StatusTooRecent
+		} else if newRemainingSpace := remainingSpace - path.Size(); newRemainingSpace >= 0 {
+			output = append(output, path)
+			remainingSpace = newRemainingSpace
+			decision.Status = StatusSelected
+		} else {
+			decision.Status = StatusOverBudget
 		}
-	}
-	return duplicates
-}
 
-// GetOldestDuplicates returns all duplicate files as a single slice, but omits
-// the file with the most recent mtime for each group of duplicates.
-func GetOldestDuplicates(paths FilePaths) (duplicates FilePaths) {
-	allDuplicates := GetDuplicates(paths)
-	for _, group := range allDuplicates {
-		sort.Slice(group, func(i, j int) bool {
-			return group[i].Stat.ModTime().After(group[j].Stat.ModTime())
-		})
-		duplicates = append(duplicates, group[1:]...)
+		decisions[path.Path] = decision
 	}
 
-	return duplicates
+	return output, decisions
 }
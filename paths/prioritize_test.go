@@ -23,66 +23,46 @@ import (
 	"testing"
 	"os"
 	"time"
+	"path/filepath"
 )
 
-func TestGetDuplicates(t *testing.T) {
-	tempPath, teardownFunc := setupFiles(t)
-	defer teardownFunc()
-
-	contents := fileContents {
-		{"letters/a.txt", "aaa"},
-		{"letters/upper/A.txt", "aaa"},
-		{"numbers/1.txt", "111"},
-	}
-	err := writeFiles(contents)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	duplicatePaths := GetDuplicates(*pathsToTest)
-	expectedPaths := []string{"letters/a.txt", "letters/upper/A.txt"}
-
-	assertPathsEqual(t, duplicatePaths[0], expectedPaths, tempPath)
-}
-
-func TestGetOldestDuplicates(t *testing.T) {
+func TestFilter(t *testing.T) {
 	tempPath, teardownFunc := setupFiles(t)
 	defer teardownFunc()
 
 	contents := fileContents {
-		{"letters/a.txt", "aaa"},
-		{"letters/upper/A.txt", "aaa"},
-		{"numbers/1.txt", "111"},
+		{"letters/a.txt", "a"},
+		{"letters/upper/A.txt", "A"},
+		{"numbers/1.txt", "11"},
 	}
 	err := writeFiles(contents)
 	if err != nil {
 		t.Fatal(err)
 	}
+	os.Chtimes("letters/a.txt", time.Now().Add(-time.Second * 3), time.Now())
+	os.Chtimes("letters/upper/A.txt", time.Now().Add(-time.Second), time.Now())
 
-	// Change the mtime to a time in the past.
-	os.Chtimes("letters/upper/A.txt", time.Now(), time.Now().Add(-time.Second))
-
+	// Because it is limited to 2 bytes, Filter must choose a.txt and A.txt or
+	// it must choose 1.txt. Because 1.txt has a more recent atime, it will
+	// not choose 1.txt. Because the atime of A.txt is beyond the limit, it
+	// will not choose A.txt. This leaves a.txt.
 	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	duplicatePaths := GetOldestDuplicates(*pathsToTest)
-	expectedPaths := []string{"letters/upper/A.txt"}
+	filteredPaths := Filter(*pathsToTest, 2, time.Second * 2)
+	expectedPaths := []string{"letters/a.txt"}
 
-	assertPathsEqual(t, duplicatePaths, expectedPaths, tempPath)
+	assertPathsEqual(t, filteredPaths, expectedPaths, tempPath)
 }
 
-func TestFilter(t *testing.T) {
+func TestExplainFilter(t *testing.T) {
 	tempPath, teardownFunc := setupFiles(t)
 	defer teardownFunc()
 
 	contents := fileContents {
 		{"letters/a.txt", "a"},
-		{"letters/upper/A.txt", "A"},
+		{"letters/upper/A.txt", "AAA"},
 		{"numbers/1.txt", "11"},
 	}
 	err := writeFiles(contents)
@@ -90,18 +70,33 @@ func TestFilter(t *testing.T) {
 		t.Fatal(err)
 	}
 	os.Chtimes("letters/a.txt", time.Now().Add(-time.Second * 3), time.Now())
-	os.Chtimes("letters/upper/A.txt", time.Now().Add(-time.Second), time.Now())
+	os.Chtimes("letters/upper/A.txt", time.Now().Add(-time.Second * 3), time.Now())
 
-	// Because it is limited to 2 bytes, Filter must choose a.txt and A.txt or
-	// it must choose 1.txt. Because 1.txt has a more recent atime, it will
-	// not choose 1.txt. Because the atime of A.txt is beyond the limit, it
-	// will not choose A.txt. This leaves a.txt.
+	// A.txt has the highest priority, but it doesn't fit within 2 bytes.
+	// a.txt fits, which leaves no space for 1.txt.
 	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
 	if err != nil {
 		t.Fatal(err)
 	}
-	filteredPaths := Filter(*pathsToTest, 2, time.Second * 2)
-	expectedPaths := []string{"letters/a.txt"}
+	decisions := ExplainFilter(*pathsToTest, 2, time.Second * 2)
+
+	testCases := []struct {
+		Path string
+		Status FilterStatus
+	}{
+		{"letters/a.txt", StatusSelected},
+		{"letters/upper/A.txt", StatusOverBudget},
+		{"numbers/1.txt", StatusTooRecent},
+	}
 
-	assertPathsEqual(t, filteredPaths, expectedPaths, tempPath)
+	for _, tc := range testCases {
+		decision := decisions[filepath.Join(tempPath, tc.Path)]
+		if decision.Status != tc.Status {
+			t.Errorf("Path: %v, Status: %v", tc.Path, decision.Status)
+		}
+	}
+
+	if position := decisions[filepath.Join(tempPath, "letters/upper/A.txt")].Position; position != 1 {
+		t.Errorf("Position: %v", position)
+	}
 }
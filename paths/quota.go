@@ -0,0 +1,191 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"os"
+	"bufio"
+	"regexp"
+	"log"
+	"strings"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/lostatc/reddup/parse"
+)
+
+// Quota is the maximum number of bytes that the files in a subtree may use.
+type Quota struct {
+	Path string
+	MaxSize int64
+	UsedSize int64
+}
+
+type Quotas []Quota
+
+// NewQuotasFromFile reads quotas from the file at path. Each line of the file
+// contains a subtree relative to startDir followed by a human-readable file
+// size. Lines starting with a hash symbol are comments.
+func NewQuotasFromFile(path string, startDir string) (Quotas, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	commentRegex, err := regexp.Compile(CommentPattern)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var quotas Quotas
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || commentRegex.FindString(line) != "" {
+			continue
+		}
+
+		// The size is the last field so that subtrees may contain spaces.
+		separator := strings.LastIndexAny(line, " \t")
+		if separator < 0 {
+			return nil, fmt.Errorf("the line '%s' does not contain a subtree and a size", line)
+		}
+		subtree := strings.TrimSpace(line[:separator])
+		maxSize, err := parse.ReadFileSize(line[separator + 1:])
+		if err != nil {
+			return nil, err
+		}
+
+		quotas = append(quotas, Quota{Path: filepath.Join(startDir, subtree), MaxSize: maxSize})
+	}
+
+	return quotas, scanner.Err()
+}
+
+// Match returns the index of the quota with the most specific subtree that
+// contains checkPath. If no subtree contains checkPath, it returns -1.
+func (q Quotas) Match(checkPath string) int {
+	match := -1
+	for i, quota := range q {
+		relPath, err := filepath.Rel(quota.Path, checkPath)
+		if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".." + string(os.PathSeparator)) {
+			continue
+		}
+
+		if match < 0 || len(quota.Path) > len(q[match].Path) {
+			match = i
+		}
+	}
+
+	return match
+}
+
+// Measure returns a copy of these quotas with the used size of each one set to
+// the total size of the files in paths that it matches. Each file counts
+// toward only the most specific subtree that contains it.
+func (q Quotas) Measure(paths FilePaths) Quotas {
+	output := make(Quotas, len(q))
+	copy(output, q)
+	for i := range output {
+		output[i].UsedSize = 0
+	}
+
+	for _, path := range paths {
+		if i := output.Match(path.Path); i >= 0 {
+			output[i].UsedSize += path.Stat.Size()
+		}
+	}
+
+	return output
+}
+
+// Reclaim returns a copy of these quotas with the size of the files in paths
+// subtracted from the used size of the quota that each one matches. The files
+// in a directory which is handled as a single unit are matched individually.
+func (q Quotas) Reclaim(paths FilePaths) Quotas {
+	output := make(Quotas, len(q))
+	copy(output, q)
+
+	for _, path := range paths {
+		if path.Metadata.Contents != nil {
+			output = output.Reclaim(path.Metadata.Contents)
+		} else if i := output.Match(path.Path); i >= 0 {
+			output[i].UsedSize -= path.Stat.Size()
+		}
+	}
+
+	return output
+}
+
+// FilterQuotas returns the files with the largest size and least recent atime
+// that must be cleaned up to bring each subtree in quotas under its maximum
+// size. Only files which were last accessed at least minDuration in the past
+// are selected. Files which don't belong to any subtree are ignored.
+func FilterQuotas(paths FilePaths, quotas Quotas, minDuration time.Duration) FilePaths {
+	output, _ := filterQuotas(paths, quotas, minDuration)
+	return output
+}
+
+// ExplainFilterQuotas returns a map of file paths to decisions that describe
+// how FilterQuotas treats each of the given files.
+func ExplainFilterQuotas(paths FilePaths, quotas Quotas, minDuration time.Duration) map[string]FilterDecision {
+	_, decisions := filterQuotas(paths, quotas, minDuration)
+	return decisions
+}
+
+// filterQuotas is the implementation of FilterQuotas and ExplainFilterQuotas.
+func filterQuotas(paths FilePaths, quotas Quotas, minDuration time.Duration) (FilePaths, map[string]FilterDecision) {
+	priorities := prioritize(paths)
+	remaining := quotas.Reclaim(nil)
+	output := make(FilePaths, 0)
+	decisions := make(map[string]FilterDecision)
+	maxAtime := time.Now().Add(-minDuration)
+
+	for position, priority := range priorities {
+		path := priority.File
+		i := remaining.Match(path.Path)
+		if i < 0 {
+			continue
+		}
+		decision := FilterDecision {
+			Priority: priority.Priority,
+			Position: position + 1,
+			Remaining: remaining[i].UsedSize - remaining[i].MaxSize,
+		}
+
+		if path.Size() == 0 {
+			decision.Status = StatusEmpty
+		} else if path.Time.AccessTime().After(maxAtime) {
+			decision.Status = StatusTooRecent
+		} else if remaining[i].UsedSize <= remaining[i].MaxSize {
+			decision.Status = StatusUnderQuota
+		} else {
+			output = append(output, path)
+			remaining[i].UsedSize -= path.Size()
+			decision.Status = StatusSelected
+		}
+
+		decisions[path.Path] = decision
+	}
+
+	return output, decisions
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"testing"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const quotaTestFileContents = `
+# Comment
+letters  3KB
+letters/upper	1KiB
+  my documents 10MB
+`
+
+func TestNewQuotasFromFile(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "reddup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+	tempFile.WriteString(quotaTestFileContents)
+
+	quotas, err := NewQuotasFromFile(tempFile.Name(), "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedQuotas := Quotas {
+		{Path: "/dir/letters", MaxSize: 3000},
+		{Path: "/dir/letters/upper", MaxSize: 1024},
+		{Path: "/dir/my documents", MaxSize: 10000000},
+	}
+	if len(quotas) != len(expectedQuotas) {
+		t.Fatalf("%v != %v", quotas, expectedQuotas)
+	}
+	for i, quota := range quotas {
+		if quota != expectedQuotas[i] {
+			t.Errorf("%v != %v", quota, expectedQuotas[i])
+		}
+	}
+}
+
+func TestQuotasMatch(t *testing.T) {
+	quotas := Quotas {
+		{Path: "/dir/letters"},
+		{Path: "/dir/letters/upper"},
+	}
+
+	testCases := []struct {
+		CheckPath string
+		Expected int
+	}{
+		{"/dir/letters/a.txt", 0},
+		{"/dir/letters/upper/A.txt", 1},
+		{"/dir/letters-old/a.txt", -1},
+		{"/dir/numbers/1.txt", -1},
+	}
+
+	for _, tc := range testCases {
+		if result := quotas.Match(tc.CheckPath); result != tc.Expected {
+			t.Errorf("got %v, expected %v", result, tc.Expected)
+		}
+	}
+}
+
+func TestFilterQuotas(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "aa"},
+		{"letters/upper/A.txt", "AAAA"},
+		{"numbers/1.txt", "1111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes("letters/a.txt", time.Now().Add(-time.Second * 2), time.Now())
+	os.Chtimes("letters/upper/A.txt", time.Now().Add(-time.Second), time.Now())
+
+	// The letters subtree uses 6 bytes and must be brought under 3 bytes.
+	// A.txt is chosen first because it is larger, which is enough to bring
+	// the subtree under its quota. The numbers subtree has no quota.
+	pathsToTest, err := NewFilePathsFromRel(testingFilePaths, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotas := Quotas{{Path: tempPath + "/letters", MaxSize: 3}}.Measure(*pathsToTest)
+	if quotas[0].UsedSize != 6 {
+		t.Fatalf("used size %v != 6", quotas[0].UsedSize)
+	}
+
+	filteredPaths := FilterQuotas(*pathsToTest, quotas, 0)
+	expectedPaths := []string{"letters/upper/A.txt"}
+
+	assertPathsEqual(t, filteredPaths, expectedPaths, tempPath)
+}
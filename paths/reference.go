@@ -0,0 +1,153 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ScanReferences returns all the files in the trees rooted at each of
+// refDirs.
+func ScanReferences(refDirs []string) (FilePaths, error) {
+	var refPaths FilePaths
+	for _, refDir := range refDirs {
+		treePaths, err := ScanTree(refDir, ModeFile)
+		if err != nil {
+			return nil, err
+		}
+		refPaths = append(refPaths, treePaths...)
+	}
+
+	return refPaths, nil
+}
+
+// FindBackedUp splits groups of identical files into the groups which have a
+// copy in refPaths and the groups which don't. Each group with a copy in
+// refPaths is reduced to one of those copies followed by every file which is
+// not in refPaths. Those files have their Metadata.BackedUp field set and
+// their Metadata.Kept field set to the path of the copy in refPaths. A copy in
+// refPaths which is the same file as one of the other files, like a hard link
+// or a path through a symbolic link, is not a backup of it and is ignored.
+func FindBackedUp(groups []FilePaths, refPaths FilePaths) (backedUp []FilePaths, other []FilePaths) {
+	isReference := make(map[string]bool)
+	for _, path := range refPaths {
+		isReference[path.Path] = true
+	}
+
+	for _, group := range groups {
+		var refCopies, sourceCopies FilePaths
+		for _, path := range group {
+			if isReference[path.Path] {
+				refCopies = append(refCopies, path)
+			} else {
+				sourceCopies = append(sourceCopies, path)
+			}
+		}
+		if len(sourceCopies) == 0 {
+			continue
+		}
+
+		var refCopy *FilePath
+		for i := range refCopies {
+			if !sameAsAny(refCopies[i], sourceCopies) {
+				refCopy = &refCopies[i]
+				break
+			}
+		}
+
+		if refCopy == nil {
+			if len(sourceCopies) > 1 {
+				other = append(other, sourceCopies)
+			}
+			continue
+		}
+
+		for i := range sourceCopies {
+			sourceCopies[i].Metadata.BackedUp = true
+			sourceCopies[i].Metadata.Kept = refCopy.Path
+		}
+		backedUp = append(backedUp, append(FilePaths{*refCopy}, sourceCopies...))
+	}
+
+	return backedUp, other
+}
+
+// sameAsAny returns true if path is the same file as any file in others.
+func sameAsAny(path FilePath, others FilePaths) bool {
+	pathInfo, err := os.Stat(path.Path)
+	if err != nil {
+		return true
+	}
+	for _, other := range others {
+		if otherInfo, err := os.Stat(other.Path); err == nil && os.SameFile(pathInfo, otherInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveReferences returns the absolute paths of refDirs with symbolic links
+// resolved so that they can be passed to InReferences. Directories which can't
+// be resolved are left out.
+func ResolveReferences(refDirs []string) []string {
+	var resolvedDirs []string
+	for _, refDir := range refDirs {
+		if absDir, err := resolvePath(refDir); err == nil {
+			resolvedDirs = append(resolvedDirs, absDir)
+		}
+	}
+	return resolvedDirs
+}
+
+// InReferences returns true if checkPath is in the tree rooted at any of
+// refDirs, which must be resolved by ResolveReferences first. Symbolic links in
+// checkPath are resolved too.
+func InReferences(checkPath string, refDirs []string) bool {
+	if len(refDirs) == 0 {
+		return false
+	}
+
+	absPath, err := resolvePath(checkPath)
+	if err != nil {
+		return false
+	}
+
+	for _, refDir := range refDirs {
+		if isUnderDir(absPath, refDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolvePath returns the absolute path of path with symbolic links resolved.
+// If the file doesn't exist, it returns the absolute path instead.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolvedPath, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolvedPath, nil
+	}
+	return absPath, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"path/filepath"
+)
+
+func TestFindBackedUp(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	os.MkdirAll("backup/letters", 0700)
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+		{"numbers/1.txt", "111"},
+		{"backup/letters/a.txt", "aaa"},
+		{"backup/2.txt", "222"},
+		{"backup/3.txt", "222"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sourcePaths, err := ScanTree(filepath.Join(tempPath, "letters"), ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refPaths, err := ScanReferences([]string{filepath.Join(tempPath, "backup")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both copies in the source are backed up. The group which only has
+	// copies in the reference tree is dropped.
+	groups := NewDuplicateFinder().Find(append(sourcePaths, refPaths...))
+	backedUp, other := FindBackedUp(groups, refPaths)
+	if len(backedUp) != 1 || len(other) != 0 {
+		t.Fatalf("%v backed up and %v other groups != 1 and 0", len(backedUp), len(other))
+	}
+
+	refCopy := filepath.Join(tempPath, "backup/letters/a.txt")
+	if backedUp[0][0].Path != refCopy {
+		t.Errorf("%v != %v", backedUp[0][0].Path, refCopy)
+	}
+	assertPathsEqual(t, backedUp[0][1:], []string{"letters/a.txt", "letters/upper/A.txt"}, tempPath)
+	for _, path := range backedUp[0][1:] {
+		if !path.Metadata.BackedUp || path.Metadata.Kept != refCopy {
+			t.Errorf("%v is not backed up by %v", path.Path, refCopy)
+		}
+	}
+}
+
+func TestFindBackedUpSameFile(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	os.MkdirAll("backup", 0700)
+	contents := fileContents {
+		{"letters/a.txt", "aaa"},
+		{"letters/upper/A.txt", "aaa"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The only copy in the reference tree is a hard link to a file in the
+	// source, so it doesn't back anything up.
+	if err := os.Link("letters/a.txt", "backup/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	sourcePaths, err := ScanTree(filepath.Join(tempPath, "letters"), ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refPaths, err := ScanReferences([]string{filepath.Join(tempPath, "backup")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := []FilePaths{append(append(FilePaths{}, sourcePaths...), refPaths...)}
+	backedUp, other := FindBackedUp(groups, refPaths)
+	if len(backedUp) != 0 || len(other) != 1 {
+		t.Fatalf("%v backed up and %v other groups != 0 and 1", len(backedUp), len(other))
+	}
+	assertPathsEqual(t, other[0], []string{"letters/a.txt", "letters/upper/A.txt"}, tempPath)
+}
+
+func TestInReferences(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	// A reference tree given through a symbolic link contains the files in
+	// the directory that the link points to.
+	if err := os.Symlink(filepath.Join(tempPath, "letters"), filepath.Join(tempPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Path string
+		Expected bool
+	}{
+		{"letters/a.txt", true},
+		{"letters/upper/A.txt", true},
+		{"link/a.txt", true},
+		{"numbers/1.txt", false},
+	}
+
+	refDirs := ResolveReferences([]string{filepath.Join(tempPath, "link")})
+	for _, tc := range testCases {
+		actual := InReferences(filepath.Join(tempPath, tc.Path), refDirs)
+		if actual != tc.Expected {
+			t.Errorf("%v: %v != %v", tc.Path, actual, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirTotal is the number and total size of the files in a directory and its
+// subdirectories.
+type DirTotal struct {
+	Path string
+	Depth int
+	Size int64
+	Files int
+}
+
+// RollupDirs returns the total size and number of the files in paths which
+// are in each directory under root, including root itself. Files in
+// subdirectories deeper than maxDepth are counted in their ancestor at
+// maxDepth. The files in directories and groups of companion files which are
+// handled as single units are counted individually. Files which are not in
+// root are ignored.
+//
+// The directories are returned in depth-first order starting with root, and
+// the subdirectories of each directory are sorted by size from largest to
+// smallest.
+func RollupDirs(paths FilePaths, root string, maxDepth int) []DirTotal {
+	root = filepath.Clean(root)
+	totals := map[string]*DirTotal{root: {Path: root}}
+	children := make(map[string][]string)
+
+	addFile := func(filePath FilePath) {
+		if filePath.Path == root || !isUnderDir(filePath.Path, root) {
+			return
+		}
+		relDir, _ := filepath.Rel(root, filepath.Dir(filePath.Path))
+		var parts []string
+		if relDir != "." {
+			parts = strings.Split(relDir, string(filepath.Separator))
+		}
+
+		dir := root
+		for depth := 0; ; depth++ {
+			total, ok := totals[dir]
+			if !ok {
+				total = &DirTotal{Path: dir, Depth: depth}
+				totals[dir] = total
+				parent := filepath.Dir(dir)
+				children[parent] = append(children[parent], dir)
+			}
+			total.Size += filePath.Size()
+			total.Files++
+
+			if depth == len(parts) || depth == maxDepth {
+				break
+			}
+			dir = filepath.Join(dir, parts[depth])
+		}
+	}
+
+	for _, filePath := range paths {
+		if filePath.Metadata.Contents == nil {
+			addFile(filePath)
+			continue
+		}
+		for _, contentPath := range filePath.Metadata.Contents {
+			addFile(contentPath)
+		}
+	}
+
+	var output []DirTotal
+	var visit func(dir string)
+	visit = func(dir string) {
+		output = append(output, *totals[dir])
+		subdirs := children[dir]
+		sort.Slice(subdirs, func(i, j int) bool {
+			if totals[subdirs[i]].Size != totals[subdirs[j]].Size {
+				return totals[subdirs[i]].Size > totals[subdirs[j]].Size
+			}
+			return subdirs[i] < subdirs[j]
+		})
+		for _, subdir := range subdirs {
+			visit(subdir)
+		}
+	}
+	visit(root)
+
+	return output
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRollupDirs(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	contents := fileContents {
+		{"letters/a.txt", "a"},
+		{"letters/upper/A.txt", "AAAA"},
+		{"numbers/1.txt", "111"},
+	}
+	err := writeFiles(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allPaths, err := ScanTree(tempPath, ModeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		TestName string
+		Root string
+		MaxDepth int
+		Expected []DirTotal
+	}{
+		{"Depth 1", tempPath, 1, []DirTotal{
+			{tempPath, 0, 8, 3},
+			{filepath.Join(tempPath, "letters"), 1, 5, 2},
+			{filepath.Join(tempPath, "numbers"), 1, 3, 1},
+		}},
+		{"Depth 2", tempPath, 2, []DirTotal{
+			{tempPath, 0, 8, 3},
+			{filepath.Join(tempPath, "letters"), 1, 5, 2},
+			{filepath.Join(tempPath, "letters/upper"), 2, 4, 1},
+			{filepath.Join(tempPath, "numbers"), 1, 3, 1},
+		}},
+		{"Subtree", filepath.Join(tempPath, "letters"), 1, []DirTotal{
+			{filepath.Join(tempPath, "letters"), 0, 5, 2},
+			{filepath.Join(tempPath, "letters/upper"), 1, 4, 1},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			returned := RollupDirs(allPaths, tc.Root, tc.MaxDepth)
+			if !reflect.DeepEqual(returned, tc.Expected) {
+				t.Fatalf("\nExpected: %v\nReturned: %v", tc.Expected, returned)
+			}
+		})
+	}
+}
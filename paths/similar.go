@@ -0,0 +1,261 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"runtime"
+	"math/bits"
+	"path/filepath"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// These are the file extensions of images that can be compared.
+var imageExtensions = map[string]struct{} {
+	".jpg": {}, ".jpeg": {}, ".png": {}, ".gif": {},
+}
+
+// The perceptual hash of an image is computed from a grayscale version of it
+// with these dimensions. Each bit of the hash is whether a pixel is brighter
+// than the one to its right.
+const (
+	imageHashWidth = 9
+	imageHashHeight = 8
+)
+
+// This is the number of pixels in each direction that are sampled from each
+// region of the image that is reduced to one pixel.
+const imageHashSamples = 8
+
+// ImageHash is a perceptual hash of an image. Similar images have hashes which
+// differ in only a few bits.
+type ImageHash uint64
+
+// Distance returns the number of bits which differ between two hashes.
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+// imageInfo holds the perceptual hash and resolution of an image.
+type imageInfo struct {
+	Path FilePath
+	Hash ImageHash
+	Pixels int
+}
+
+// SimilarImageFinder finds groups of images that look alike, such as the same
+// picture saved at different resolutions or recompressed. Images are compared
+// using a difference hash (dHash).
+type SimilarImageFinder struct {
+	// This is the maximum number of bits which may differ between the hashes
+	// of two images for them to be considered similar.
+	MaxDistance int
+
+	// This is the number of images which are decoded concurrently.
+	Workers int
+}
+
+// NewSimilarImageFinder creates a new SimilarImageFinder with default
+// settings.
+func NewSimilarImageFinder() *SimilarImageFinder {
+	return &SimilarImageFinder {
+		MaxDistance: 10,
+		Workers: runtime.NumCPU(),
+	}
+}
+
+// Find determines which of the given files are similar images and returns
+// them. Each FilePaths slice in the slice that is returned represents a group
+// of similar images. Each group is sorted so that the image with the highest
+// resolution comes first, with ties broken by file size, and every other image
+// in the group is within MaxDistance of that first image. Files which are not
+// JPEG, PNG or GIF images are ignored.
+func (s *SimilarImageFinder) Find(paths FilePaths) (similar []FilePaths) {
+	for _, group := range groupImages(s.hashAll(paths), s.MaxDistance) {
+		if len(group) < 2 {
+			continue
+		}
+
+		var output FilePaths
+		for _, info := range group {
+			info.Path.Metadata.Similar = true
+			output = append(output, info.Path)
+		}
+		similar = append(similar, output)
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		return similar[i][0].Path < similar[j][0].Path
+	})
+
+	return similar
+}
+
+// groupImages splits images into groups of similar images. The images are
+// visited from the highest resolution to the lowest, and each one joins the
+// first group whose first image is within maxDistance of it or else starts a
+// new group. Images are only compared with the first image of each group
+// because that is the one which is kept, so a chain of images which each look
+// like the next doesn't join images which look nothing alike.
+func groupImages(images []imageInfo, maxDistance int) (groups [][]imageInfo) {
+	sorted := make([]imageInfo, len(images))
+	copy(sorted, images)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Pixels != sorted[j].Pixels {
+			return sorted[i].Pixels > sorted[j].Pixels
+		}
+		if sorted[i].Path.Stat.Size() != sorted[j].Path.Stat.Size() {
+			return sorted[i].Path.Stat.Size() > sorted[j].Path.Stat.Size()
+		}
+		return sorted[i].Path.Path < sorted[j].Path.Path
+	})
+
+	for _, info := range sorted {
+		joined := false
+		for i, group := range groups {
+			if group[0].Hash.Distance(info.Hash) <= maxDistance {
+				groups[i] = append(group, info)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			groups = append(groups, []imageInfo{info})
+		}
+	}
+
+	return groups
+}
+
+// hashAll concurrently hashes the images in paths. Files which are not images
+// or can't be decoded are omitted.
+func (s *SimilarImageFinder) hashAll(paths FilePaths) (images []imageInfo) {
+	jobs := make(chan FilePath, 100)
+	var imagesLock sync.Mutex
+	var wg sync.WaitGroup
+
+	// Start workers.
+	for i := 0; i < maxInt(s.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				hash, pixels, err := imageHash(path.Path)
+				if err != nil {
+					continue
+				}
+
+				imagesLock.Lock()
+				images = append(images, imageInfo{Path: path, Hash: hash, Pixels: pixels})
+				imagesLock.Unlock()
+			}
+		}()
+	}
+
+	// Pass images to workers.
+	for _, path := range paths {
+		if _, ok := imageExtensions[strings.ToLower(filepath.Ext(path.Path))]; ok {
+			jobs <- path
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Sort the images so that the output for a given input is always the same.
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Path.Path < images[j].Path.Path
+	})
+
+	return images
+}
+
+// imageHash decodes the image at path and returns its perceptual hash and the
+// number of pixels in it.
+func imageHash(path string) (hash ImageHash, pixels int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bounds := img.Bounds()
+	return dHash(img), bounds.Dx() * bounds.Dy(), nil
+}
+
+// dHash returns the difference hash of img. The image is reduced to a small
+// grayscale image by averaging samples from each region of it, and each bit of
+// the hash is whether a pixel in the small image is brighter than the one to
+// its right.
+func dHash(img image.Image) (hash ImageHash) {
+	bounds := img.Bounds()
+	var gray [imageHashHeight][imageHashWidth]float64
+
+	for y := 0; y < imageHashHeight; y++ {
+		for x := 0; x < imageHashWidth; x++ {
+			var total float64
+			for sy := 0; sy < imageHashSamples; sy++ {
+				for sx := 0; sx < imageHashSamples; sx++ {
+					px := bounds.Min.X + (x * imageHashSamples + sx) * bounds.Dx() / (imageHashWidth * imageHashSamples)
+					py := bounds.Min.Y + (y * imageHashSamples + sy) * bounds.Dy() / (imageHashHeight * imageHashSamples)
+					total += float64(color.GrayModel.Convert(img.At(px, py)).(color.Gray).Y)
+				}
+			}
+			gray[y][x] = total
+		}
+	}
+
+	for y := 0; y < imageHashHeight; y++ {
+		for x := 0; x < imageHashWidth - 1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x + 1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// SmallerImages returns all similar images in groups as a single slice, but
+// omits the image in each group with the highest resolution. Every image that
+// is returned has its Metadata.Kept field set to the path of that image.
+func SmallerImages(groups []FilePaths) (smaller FilePaths) {
+	for _, group := range groups {
+		for i := range group[1:] {
+			group[i + 1].Metadata.Kept = group[0].Path
+		}
+		smaller = append(smaller, group[1:]...)
+	}
+
+	return smaller
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package paths
+
+import (
+	"testing"
+	"os"
+	"path/filepath"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// writeImage writes an image with the given dimensions to path. The brightness
+// of each pixel is computed by shade from its position relative to the size of
+// the image.
+func writeImage(t *testing.T, path string, width, height int, shade func(x, y float64) uint8) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{shade(float64(x) / float64(width), float64(y) / float64(height))})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".png" {
+		err = png.Encode(file, img)
+	} else {
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: 50})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimilarImageFinder(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	gradient := func(x, y float64) uint8 {
+		return uint8(255 * (x + y) / 2)
+	}
+	stripes := func(x, y float64) uint8 {
+		return uint8(255 * (int(x * 8) % 2))
+	}
+
+	writeImage(t, "letters/large.png", 128, 96, gradient)
+	writeImage(t, "letters/upper/small.jpg", 32, 24, gradient)
+	writeImage(t, "numbers/other.png", 128, 96, stripes)
+
+	pathsToTest, err := NewFilePathsFromRel(
+		[]string{"letters/large.png", "letters/upper/small.jpg", "numbers/other.png", "letters/a.txt"}, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := NewSimilarImageFinder().Find(*pathsToTest)
+
+	if len(groups) != 1 {
+		t.Fatalf("%d groups != 1 group: %v", len(groups), groups)
+	}
+	assertPathsEqual(t, groups[0], []string{"letters/large.png", "letters/upper/small.jpg"}, tempPath)
+	if groups[0][0].Path != filepath.Join(tempPath, "letters/large.png") {
+		t.Errorf("the image with the highest resolution is not first: %v", groups[0])
+	}
+
+	smaller := SmallerImages(groups)
+	assertPathsEqual(t, smaller, []string{"letters/upper/small.jpg"}, tempPath)
+	if smaller[0].Metadata.Kept != filepath.Join(tempPath, "letters/large.png") {
+		t.Errorf("Kept: %v", smaller[0].Metadata.Kept)
+	}
+}
+
+func TestGroupImages(t *testing.T) {
+	tempPath, teardownFunc := setupFiles(t)
+	defer teardownFunc()
+
+	pathsToTest, err := NewFilePathsFromRel([]string{"letters/a.txt", "letters/upper/A.txt", "numbers/1.txt"}, tempPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each image is within the maximum distance of the next one, but the first
+	// and the last are not within the maximum distance of each other.
+	images := []imageInfo {
+		{Path: (*pathsToTest)[0], Hash: 0x0, Pixels: 300},
+		{Path: (*pathsToTest)[1], Hash: 0x7, Pixels: 200},
+		{Path: (*pathsToTest)[2], Hash: 0x3f, Pixels: 100},
+	}
+	groups := groupImages(images, 3)
+
+	if len(groups) != 2 {
+		t.Fatalf("%d groups != 2 groups: %v", len(groups), groups)
+	}
+	var firstGroup FilePaths
+	for _, info := range groups[0] {
+		firstGroup = append(firstGroup, info.Path)
+	}
+	assertPathsEqual(t, firstGroup, []string{"letters/a.txt", "letters/upper/A.txt"}, tempPath)
+	if len(groups[1]) != 1 || groups[1][0].Path.Path != filepath.Join(tempPath, "numbers/1.txt") {
+		t.Errorf("the last image is not in its own group: %v", groups[1])
+	}
+}
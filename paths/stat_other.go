@@ -0,0 +1,48 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"os"
+)
+
+// getOwner always returns an empty string on this platform.
+func getOwner(info os.FileInfo) string {
+	return ""
+}
+
+// getLinkCount always returns 1 on this platform.
+func getLinkCount(info os.FileInfo) uint64 {
+	return 1
+}
+
+// getAllocatedSize returns the size of the file described by info on this
+// platform.
+func getAllocatedSize(info os.FileInfo) int64 {
+	return info.Size()
+}
+
+// getInode always returns 0 on this platform.
+func getInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,86 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"sync"
+)
+
+// This caches the names of users by their user ID for getOwner.
+var ownerCache = make(map[uint32]string)
+var ownerCacheLock sync.Mutex
+
+// getOwner returns the name of the user who owns the file described by info.
+// If the name of the user can't be found, their user ID is returned instead.
+func getOwner(info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	ownerCacheLock.Lock()
+	defer ownerCacheLock.Unlock()
+	if name, ok := ownerCache[stat.Uid]; ok {
+		return name
+	}
+
+	name := strconv.FormatUint(uint64(stat.Uid), 10)
+	if owner, err := user.LookupId(name); err == nil {
+		name = owner.Username
+	}
+	ownerCache[stat.Uid] = name
+
+	return name
+}
+
+// getLinkCount returns the number of hard links to the file described by info.
+func getLinkCount(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+	return uint64(stat.Nlink)
+}
+
+// getAllocatedSize returns the number of bytes allocated on disk for the file
+// described by info. This may be smaller than its size for sparse files.
+func getAllocatedSize(info os.FileInfo) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	return int64(stat.Blocks) * 512
+}
+
+// getInode returns the inode number of the file described by info.
+func getInode(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(stat.Ino)
+}
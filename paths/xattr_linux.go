@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+import (
+	"syscall"
+)
+
+// getXattr returns the value of the extended attribute name of the file at
+// path. If the file doesn't have the attribute, errNoXattr is returned. If the
+// file system doesn't support extended attributes, errXattrUnsupported is
+// returned.
+func getXattr(path string, name string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, convertXattrError(err)
+	}
+
+	value := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, value)
+	if err != nil {
+		return nil, convertXattrError(err)
+	}
+
+	return value[:size], nil
+}
+
+// setXattr sets the extended attribute name of the file at path to value. If
+// the file system doesn't support extended attributes, errXattrUnsupported is
+// returned.
+func setXattr(path string, name string, value []byte) error {
+	return convertXattrError(syscall.Setxattr(path, name, value, 0))
+}
+
+// removeXattr removes the extended attribute name from the file at path. If
+// the file doesn't have the attribute, errNoXattr is returned. If the file
+// system doesn't support extended attributes, errXattrUnsupported is returned.
+func removeXattr(path string, name string) error {
+	return convertXattrError(syscall.Removexattr(path, name))
+}
+
+// convertXattrError converts errors returned by system calls for extended
+// attributes to errors that are independent of the platform.
+func convertXattrError(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case syscall.ENODATA:
+		return errNoXattr
+	case syscall.ENOTSUP:
+		return errXattrUnsupported
+	default:
+		return err
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package paths
+
+// getXattr always returns errXattrUnsupported on this platform.
+func getXattr(path string, name string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}
+
+// setXattr always returns errXattrUnsupported on this platform.
+func setXattr(path string, name string, value []byte) error {
+	return errXattrUnsupported
+}
+
+// removeXattr always returns errXattrUnsupported on this platform.
+func removeXattr(path string, name string) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/paths"
+)
+
+// defaultPinDatabase returns the path of the file used to store pins on file
+// systems which don't support extended attributes. If there is no home
+// directory, it returns an empty string.
+func defaultPinDatabase() string {
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if dataDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dataDir = filepath.Join(homeDir, ".local", "share")
+	}
+
+	return filepath.Join(dataDir, "reddup", "pins")
+}
+
+// pin executes the 'pin' command.
+func pin(c *cli.Context) (err error) {
+	pins, err := paths.NewPins(c.GlobalString("pin-database"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for _, path := range c.Args() {
+		if err := pins.Pin(path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	return nil
+}
+
+// unpin executes the 'unpin' command.
+func unpin(c *cli.Context) (err error) {
+	pins, err := paths.NewPins(c.GlobalString("pin-database"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for _, path := range c.Args() {
+		if err := pins.Unpin(path); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	return nil
+}
+
+// listPins executes the 'pins' command.
+func listPins(c *cli.Context) (err error) {
+	pins, err := paths.NewPins(c.GlobalString("pin-database"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	pinnedPaths, err := pins.Find(c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	for _, filePath := range pinnedPaths {
+		fmt.Println(filePath.Path)
+	}
+
+	return nil
+}
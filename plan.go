@@ -0,0 +1,117 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/paths"
+)
+
+// makePlan executes the 'plan' command.
+func makePlan(c *cli.Context) (err error) {
+	var algorithm paths.HashAlgorithm
+	if !c.Bool("no-hash") {
+		algorithm, err = paths.ParseHashAlgorithm(c.GlobalString("hash"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	result := selectPaths(c)
+	source, err := filepath.Abs(result.StartDir)
+	if err != nil {
+		return err
+	}
+	plan := paths.Plan {
+		Version: paths.PlanVersion,
+		Source: source,
+		MaxSize: result.MaxSize,
+		Created: time.Now(),
+		Entries: []paths.PlanEntry{},
+	}
+	for _, filePath := range result.Selected {
+		entry, err := paths.NewPlanEntry(filePath, algorithm)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		plan.Entries = append(plan.Entries, entry)
+	}
+
+	if c.String("output") == "" {
+		if err := plan.Write(os.Stdout); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		return nil
+	}
+
+	output, err := os.Create(c.String("output"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = plan.Write(output)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Printf("%d files written to %s\n", len(plan.Entries), c.String("output"))
+
+	return nil
+}
+
+// applyPlan executes the 'apply' command.
+func applyPlan(c *cli.Context) (err error) {
+	plan, err := paths.ReadPlan(c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	destDir := c.Args()[1]
+
+	// Check every file before moving anything. Files which changed since the
+	// plan was made or which are outside of its source directory are skipped.
+	var verifiedPaths paths.FilePaths
+	var skipped int
+	for _, entry := range plan.Entries {
+		filePath, err := entry.Verify(plan.Source, c.Bool("verify-hash"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipped %s\n", err)
+			skipped++
+			continue
+		}
+		verifiedPaths = append(verifiedPaths, filePath)
+	}
+
+	if err := paths.MoveStructuredFiles(plan.Source, verifiedPaths, destDir); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Printf("%d files moved, %d skipped\n", len(verifiedPaths), skipped)
+
+	if skipped > 0 {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/paths"
+)
+
+// applyPolicy executes the 'apply-policy' command.
+func applyPolicy(c *cli.Context) (err error) {
+	policy, err := paths.NewPolicyFromFile(c.Args()[0])
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	startDir := c.Args()[1]
+
+	result, nonExcludedPaths := scanPaths(c, startDir)
+
+	// Only look for duplicates if a rule needs them because it's slow.
+	if policy.UsesDuplicates() && !c.GlobalBool("no-duplicates") {
+		hashPaths := append(append(paths.FilePaths{}, nonExcludedPaths...), result.Protected...)
+		result.DuplicateGroups = newDuplicateFinder(c).Find(hashPaths)
+		keptDuplicates := paths.KeepDuplicates(result.DuplicateGroups, getDuplicateKeepRules(c, result))
+		result.DuplicatePaths = keptDuplicates.Difference(result.Protected)
+	}
+
+	plan := policy.Plan(nonExcludedPaths, startDir, result.DuplicatePaths)
+	if result.Where != nil {
+		for i := range plan {
+			plan[i] = result.Where.Filter(plan[i], startDir)
+		}
+	}
+
+	// Number the files in the plan and print them grouped by rule.
+	numFiles, numMoved := 0, 0
+	for i, rulePaths := range plan {
+		rule := policy.Rules[i]
+		for j := range rulePaths {
+			numFiles++
+			rulePaths[j].Metadata.Rank = numFiles
+		}
+		if rule.Action == paths.ActionMove {
+			numMoved += len(rulePaths)
+		}
+
+		if !c.Bool("no-prompt") {
+			switch rule.Action {
+			case paths.ActionMove:
+				fmt.Printf("Rule %d: %s (move %d files to %s)\n", i + 1, rule.Name, len(rulePaths), rule.Destination)
+			default:
+				fmt.Printf("Rule %d: %s (list %d files)\n", i + 1, rule.Name, len(rulePaths))
+			}
+			if len(rulePaths) > 0 {
+				printPaths(os.Stdout, rulePaths, terminalQuoter())
+			}
+			fmt.Println()
+		}
+	}
+
+	if numMoved == 0 {
+		fmt.Println("0 files moved")
+		return nil
+	}
+	if !c.Bool("no-prompt") && !promptConfirmation(fmt.Sprintf("Apply this plan and move %d files?", numMoved)) {
+		fmt.Println("0 files moved")
+		return nil
+	}
+
+	// Take the action of each rule.
+	for i, rulePaths := range plan {
+		rule := policy.Rules[i]
+		if rule.Action != paths.ActionMove {
+			continue
+		}
+
+		err := paths.MoveStructuredFiles(startDir, rulePaths, rule.Destination)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	fmt.Printf("%d files moved\n", numMoved)
+
+	return nil
+}
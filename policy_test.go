@@ -0,0 +1,70 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestApplyPolicyBadFile(t *testing.T) {
+	tempPath, err := ioutil.TempDir("", "reddup-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempPath)
+
+	malformedPath := filepath.Join(tempPath, "malformed.toml")
+	if err := ioutil.WriteFile(malformedPath, []byte("[[rule]\nname = "), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		TestName string
+		PolicyPath string
+	}{
+		{"Missing", filepath.Join(tempPath, "missing.toml")},
+		{"Malformed", malformedPath},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			flags := flag.NewFlagSet("apply-policy", flag.ContinueOnError)
+			if err := flags.Parse([]string{tc.PolicyPath, tempPath}); err != nil {
+				t.Fatal(err)
+			}
+
+			// The error must be an ExitCoder or the CLI exits silently.
+			err := applyPolicy(cli.NewContext(nil, flags, nil))
+			exitErr, ok := err.(cli.ExitCoder)
+			if !ok {
+				t.Fatalf("got %v, expected an exit error", err)
+			}
+			if exitErr.ExitCode() != 1 {
+				t.Errorf("got exit code %d, expected 1", exitErr.ExitCode())
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// reviewHelp explains each answer to the prompt in reviewPaths.
+const reviewHelp = `y - move this file
+n - don't move this file
+a - move this file and every later file in this directory
+d - don't move this file or any later file in this directory
+s - show details about this file
+u - undo the last answer
+q - quit; don't move this file or any later file
+? - print help`
+
+// reviewPaths asks the user about each file in files one at a time and returns
+// the files that the user chose to move. Prompts are written to output and
+// answers are read from input.
+func reviewPaths(input *bufio.Reader, output io.Writer, files paths.FilePaths) (selected paths.FilePaths, err error) {
+	quote := terminalQuoter()
+
+	// Each answer decides one or more files, and the files decided by each
+	// answer are kept so that it can be undone.
+	decisions := make([]bool, len(files))
+	decided := make([]bool, len(files))
+	var history [][]int
+
+	decide := func(indexes []int, move bool) {
+		for _, i := range indexes {
+			decisions[i] = move
+			decided[i] = true
+		}
+		history = append(history, indexes)
+	}
+	remainingInDir := func(start int) (indexes []int) {
+		dir := filepath.Dir(files[start].Path)
+		for i := start; i < len(files); i++ {
+			if !decided[i] && filepath.Dir(files[i].Path) == dir {
+				indexes = append(indexes, i)
+			}
+		}
+		return indexes
+	}
+
+	for current := 0; current < len(files); {
+		if decided[current] {
+			current++
+			continue
+		}
+
+		filePath := files[current]
+		fmt.Fprintf(
+			output, "\n(%d/%d) %s\n    %s, last accessed %s, duplicate: %s\n",
+			current + 1, len(files), displayPath(filePath, quote),
+			parse.FormatFileSize(filePath.Size()), filePath.Time.AccessTime().Format(timeFormat),
+			duplicateLabel(filePath))
+		if filePath.Metadata.Kept != "" {
+			fmt.Fprintf(output, "    kept copy: %s\n", quote(filePath.Metadata.Kept))
+		}
+		fmt.Fprint(output, "Move this file [y,n,a,d,s,u,q,?]? ")
+
+		answer, err := input.ReadString('\n')
+		if err == io.EOF {
+			answer = "q"
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y":
+			decide([]int{current}, true)
+		case "n":
+			decide([]int{current}, false)
+		case "a":
+			decide(remainingInDir(current), true)
+		case "d":
+			decide(remainingInDir(current), false)
+		case "s":
+			printFileDetails(output, filePath, quote)
+		case "u":
+			if len(history) == 0 {
+				fmt.Fprintln(output, "Nothing to undo.")
+				continue
+			}
+			last := history[len(history) - 1]
+			history = history[:len(history) - 1]
+			for _, i := range last {
+				decided[i] = false
+			}
+			current = last[0]
+		case "q":
+			var rest []int
+			for i := current; i < len(files); i++ {
+				if !decided[i] {
+					rest = append(rest, i)
+				}
+			}
+			decide(rest, false)
+		default:
+			fmt.Fprintln(output, reviewHelp)
+		}
+	}
+
+	for i, filePath := range files {
+		if decisions[i] {
+			selected = append(selected, filePath)
+		}
+	}
+	return selected, nil
+}
+
+// printFileDetails prints everything that is known about filePath to output.
+func printFileDetails(output io.Writer, filePath paths.FilePath, quote func(string) string) {
+	fmt.Fprintf(output, "    Rank:            %d\n", filePath.Metadata.Rank)
+	fmt.Fprintf(output, "    Size:            %s (%d bytes)\n", parse.FormatFileSize(filePath.Size()), filePath.Size())
+	fmt.Fprintf(output, "    Allocated size:  %s\n", parse.FormatFileSize(filePath.AllocatedSize()))
+	fmt.Fprintf(output, "    Last access:     %s\n", filePath.Time.AccessTime().Format(timeFormat))
+	fmt.Fprintf(output, "    Last modified:   %s\n", filePath.Time.ModTime().Format(timeFormat))
+	if filePath.Time.HasChangeTime() {
+		fmt.Fprintf(output, "    Last changed:    %s\n", filePath.Time.ChangeTime().Format(timeFormat))
+	}
+	if filePath.Time.HasBirthTime() {
+		fmt.Fprintf(output, "    Created:         %s\n", filePath.Time.BirthTime().Format(timeFormat))
+	}
+	fmt.Fprintf(output, "    Duplicate:       %s\n", duplicateLabel(filePath))
+	if filePath.Metadata.Kept != "" {
+		fmt.Fprintf(output, "    Kept copy:       %s\n", quote(filePath.Metadata.Kept))
+	}
+	for _, contentPath := range filePath.Metadata.Contents {
+		fmt.Fprintf(output, "    Contains:        %s\n", quote(contentPath.Path))
+	}
+}
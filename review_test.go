@@ -0,0 +1,82 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/lostatc/reddup/paths"
+)
+
+func TestReviewPaths(t *testing.T) {
+	names := []string{"letters/a.txt", "letters/b.txt", "letters/c.txt", "numbers/1.txt"}
+	files, teardownFunc := setupTestFiles(t, names...)
+	defer teardownFunc()
+
+	testCases := []struct {
+		TestName string
+		Input string
+		Expected []string
+	}{
+		{"Each file", "y\nn\ny\nn\n", []string{"letters/a.txt", "letters/c.txt"}},
+		{"Rest of directory", "n\na\nn\n", []string{"letters/b.txt", "letters/c.txt"}},
+		{"None in directory", "d\ny\n", []string{"numbers/1.txt"}},
+		{"Undo", "y\nn\nu\ny\nn\nn\n", []string{"letters/a.txt", "letters/b.txt"}},
+		{"Undo directory", "a\nu\nn\ny\nn\ny\n", []string{"letters/b.txt", "numbers/1.txt"}},
+		{"Nothing to undo", "u\ny\nq\n", []string{"letters/a.txt"}},
+		{"Details and help", "s\n?\ny\nq\n", []string{"letters/a.txt"}},
+		{"Quit", "y\nq\n", []string{"letters/a.txt"}},
+		{"End of input", "y\ny\n", []string{"letters/a.txt", "letters/b.txt"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			input := bufio.NewReader(strings.NewReader(tc.Input))
+			selected, err := reviewPaths(input, ioutil.Discard, files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSelected(t, selected, files, names, tc.Expected)
+		})
+	}
+}
+
+// assertSelected checks that selected contains the files in files whose
+// names in names are in expected.
+func assertSelected(t *testing.T, selected, files paths.FilePaths, names, expected []string) {
+	var expectedPaths paths.FilePaths
+	for _, name := range expected {
+		for i := range names {
+			if names[i] == name {
+				expectedPaths = append(expectedPaths, files[i])
+			}
+		}
+	}
+	if !selected.Equals(expectedPaths) {
+		var selectedPaths []string
+		for _, filePath := range selected {
+			selectedPaths = append(selectedPaths, filePath.Path)
+		}
+		t.Errorf("%v != %v", selectedPaths, expected)
+	}
+}
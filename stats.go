@@ -0,0 +1,284 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// ageBuckets are the lower bounds of the buckets for the time since each file
+// was last accessed.
+var ageBuckets = []string{"0h", "1m", "3m", "6m", "1y", "2y"}
+
+// sizeBuckets are the lower bounds of the buckets for the size of each file.
+var sizeBuckets = []string{"0B", "4KiB", "1MiB", "100MiB", "1GiB"}
+
+// statsBucket is the number and total size of the files in one bucket of a
+// histogram.
+type statsBucket struct {
+	Label string `json:"label"`
+	Bytes int64 `json:"bytes"`
+	Files int `json:"files"`
+}
+
+// statsExtension is the number and total size of the files with an extension.
+type statsExtension struct {
+	Extension string `json:"extension"`
+	Bytes int64 `json:"bytes"`
+	Files int `json:"files"`
+}
+
+// statsBudget is the number of bytes which would be cleaned up when
+// suggesting up to a number of bytes.
+type statsBudget struct {
+	Budget int64 `json:"budget"`
+	Selected int64 `json:"selected"`
+	Files int `json:"files"`
+}
+
+// statsDuplicates is the number of bytes wasted by duplicates.
+type statsDuplicates struct {
+	Groups int `json:"groups"`
+	Copies int `json:"copies"`
+	Wasted int64 `json:"wasted"`
+}
+
+// statsOutput is the output of the 'stats' command.
+type statsOutput struct {
+	SchemaVersion int `json:"schema_version"`
+	Source string `json:"source"`
+	Files int `json:"files"`
+	Bytes int64 `json:"bytes"`
+	AgeHistogram []statsBucket `json:"age_histogram"`
+	SizeHistogram []statsBucket `json:"size_histogram"`
+	Extensions []statsExtension `json:"extensions"`
+	Duplicates *statsDuplicates `json:"duplicates"`
+	Budgets []statsBudget `json:"budgets"`
+}
+
+// stats executes the 'stats' command.
+func stats(c *cli.Context) (err error) {
+	format := c.String("format")
+	if format != "table" && format != "json" {
+		return cli.NewExitError(fmt.Sprintf("unknown format '%s'", format), 1)
+	}
+	var budgets []int64
+	for _, budget := range strings.Split(c.String("budgets"), ",") {
+		numBytes, err := parse.ReadFileSize(strings.TrimSpace(budget))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		budgets = append(budgets, numBytes)
+	}
+
+	// The size is irrelevant because the files which would be selected at
+	// each budget are found separately.
+	result := selectPathsFrom(c, c.Args()[0], 0)
+	output := statsOutput {
+		SchemaVersion: jsonSchemaVersion,
+		Source: result.StartDir,
+		Files: len(result.AllPaths),
+		AgeHistogram: ageHistogram(result.AllPaths, time.Now()),
+		SizeHistogram: sizeHistogram(result.AllPaths),
+		Extensions: topExtensions(result.AllPaths, c.Int("top")),
+	}
+	for _, filePath := range result.AllPaths {
+		output.Bytes += filePath.Size()
+	}
+
+	if result.FindDuplicates {
+		output.Duplicates = new(statsDuplicates)
+		for _, group := range reclaimableGroups(result) {
+			output.Duplicates.Groups++
+			output.Duplicates.Copies += len(group) - 1
+			output.Duplicates.Wasted += paths.WastedSize(group)
+		}
+	}
+
+	for _, budget := range budgets {
+		output.Budgets = append(output.Budgets, selectedAtBudget(result, budget))
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		return nil
+	}
+	printStats(os.Stdout, output)
+	return nil
+}
+
+// ageHistogram returns the number and total size of the files in filePaths
+// which were last accessed in each bucket in ageBuckets before now.
+func ageHistogram(filePaths paths.FilePaths, now time.Time) []statsBucket {
+	bounds := make([]time.Duration, len(ageBuckets))
+	histogram := make([]statsBucket, len(ageBuckets))
+	for i, bucket := range ageBuckets {
+		bounds[i], _ = parse.ReadDuration(bucket)
+		if i + 1 < len(ageBuckets) {
+			histogram[i].Label = fmt.Sprintf("%s-%s", bucket, ageBuckets[i + 1])
+		} else {
+			histogram[i].Label = fmt.Sprintf("%s+", bucket)
+		}
+	}
+
+	for _, filePath := range filePaths {
+		age := now.Sub(filePath.Time.AccessTime())
+		i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > age }) - 1
+		if i < 0 {
+			i = 0
+		}
+		histogram[i].Bytes += filePath.Size()
+		histogram[i].Files++
+	}
+
+	return histogram
+}
+
+// sizeHistogram returns the number and total size of the files in filePaths
+// with a size in each bucket in sizeBuckets.
+func sizeHistogram(filePaths paths.FilePaths) []statsBucket {
+	bounds := make([]int64, len(sizeBuckets))
+	histogram := make([]statsBucket, len(sizeBuckets))
+	for i, bucket := range sizeBuckets {
+		if bucket != "0B" {
+			bounds[i], _ = parse.ReadFileSize(bucket)
+		}
+		if i + 1 < len(sizeBuckets) {
+			histogram[i].Label = fmt.Sprintf("%s-%s", bucket, sizeBuckets[i + 1])
+		} else {
+			histogram[i].Label = fmt.Sprintf("%s+", bucket)
+		}
+	}
+
+	for _, filePath := range filePaths {
+		size := filePath.Size()
+		i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > size }) - 1
+		histogram[i].Bytes += size
+		histogram[i].Files++
+	}
+
+	return histogram
+}
+
+// topExtensions returns the limit extensions of the files in filePaths which
+// use the most bytes. Extensions are compared case-insensitively.
+func topExtensions(filePaths paths.FilePaths, limit int) []statsExtension {
+	totals := make(map[string]*statsExtension)
+	for _, filePath := range filePaths {
+		ext := strings.ToLower(filepath.Ext(filePath.Path))
+		total, ok := totals[ext]
+		if !ok {
+			total = &statsExtension{Extension: ext}
+			totals[ext] = total
+		}
+		total.Bytes += filePath.Size()
+		total.Files++
+	}
+
+	extensions := make([]statsExtension, 0, len(totals))
+	for _, total := range totals {
+		extensions = append(extensions, *total)
+	}
+	sort.Slice(extensions, func(i, j int) bool {
+		if extensions[i].Bytes != extensions[j].Bytes {
+			return extensions[i].Bytes > extensions[j].Bytes
+		}
+		return extensions[i].Extension < extensions[j].Extension
+	})
+	if len(extensions) > limit {
+		extensions = extensions[:limit]
+	}
+
+	return extensions
+}
+
+// selectedAtBudget returns the number and total size of the files that would
+// be cleaned up if up to budget bytes of files were suggested in result.
+func selectedAtBudget(result *selection, budget int64) statsBudget {
+	selected := append(paths.FilePaths{}, result.DuplicatePaths...)
+	selected = append(selected, paths.Filter(result.OtherPaths, budget, result.MinDuration)...)
+	selected = append(selected, paths.FilterQuotas(
+		result.QuotaPaths, result.Quotas.Reclaim(result.DuplicatePaths), result.MinDuration)...)
+
+	total := statsBudget{Budget: budget, Files: len(selected)}
+	for _, filePath := range selected {
+		total.Selected += filePath.Size()
+	}
+	return total
+}
+
+// printStats prints output as a series of formatted tables to writer.
+func printStats(writer io.Writer, output statsOutput) {
+	fmt.Fprintf(writer, "%d files, %s\n", output.Files, parse.FormatFileSize(output.Bytes))
+
+	table := tabwriter.NewWriter(writer, 0, 0, listPadding, ' ', 0)
+	fmt.Fprintln(table, "\nLast Access\tSize\tFiles")
+	for _, bucket := range output.AgeHistogram {
+		fmt.Fprintf(table, "%s\t%s\t%d\n", bucket.Label, parse.FormatFileSize(bucket.Bytes), bucket.Files)
+	}
+	table.Flush()
+
+	fmt.Fprintln(table, "\nFile Size\tFiles\tSize")
+	for _, bucket := range output.SizeHistogram {
+		fmt.Fprintf(table, "%s\t%d\t%s\n", bucket.Label, bucket.Files, parse.FormatFileSize(bucket.Bytes))
+	}
+	table.Flush()
+
+	fmt.Fprintln(table, "\nExtension\tSize\tFiles")
+	for _, ext := range output.Extensions {
+		name := ext.Extension
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\n", name, parse.FormatFileSize(ext.Bytes), ext.Files)
+	}
+	table.Flush()
+
+	if output.Duplicates != nil {
+		fmt.Fprintf(
+			writer, "\n%d duplicate groups, %d redundant copies, %s wasted\n",
+			output.Duplicates.Groups, output.Duplicates.Copies, parse.FormatFileSize(output.Duplicates.Wasted))
+	}
+
+	fmt.Fprintln(table, "\nBudget\tSelected\tFiles")
+	for _, budget := range output.Budgets {
+		fmt.Fprintf(
+			table, "%s\t%s\t%d\n",
+			parse.FormatFileSize(budget.Budget), parse.FormatFileSize(budget.Selected), budget.Files)
+	}
+	table.Flush()
+}
@@ -0,0 +1,454 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/lostatc/reddup/parse"
+	"github.com/lostatc/reddup/paths"
+)
+
+// These are the escape sequences used to draw the TUI.
+const (
+	escEnterScreen = "\x1b[?1049h\x1b[?25l"
+	escExitScreen = "\x1b[?25h\x1b[?1049l"
+	escHome = "\x1b[H"
+	escClearLine = "\x1b[K"
+	escClearBelow = "\x1b[J"
+	escReverse = "\x1b[7m"
+	escBold = "\x1b[1m"
+	escReset = "\x1b[0m"
+)
+
+// These are the escape sequences sent by keys which don't have a character.
+var tuiKeySequences = map[string]string {
+	"\x1b[A": "up", "\x1bOA": "up",
+	"\x1b[B": "down", "\x1bOB": "down",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdn",
+	"\x1b[H": "home", "\x1b[1~": "home", "\x1bOH": "home",
+	"\x1b[F": "end", "\x1b[4~": "end", "\x1bOF": "end",
+}
+
+// tuiSortColumns are the columns that the list can be sorted by in the order
+// that they are cycled through.
+var tuiSortColumns = []string{"rank", "size", "access", "path"}
+
+// tuiHelp is the list of keys shown at the top of the screen.
+const tuiHelp = "space: toggle  a/n: all/none  s/r: sort/reverse  /: filter  d: by directory  enter: move  q: quit"
+
+// errNotTerminal is returned when the TUI is started without a terminal.
+var errNotTerminal = errors.New("the interactive interface requires a terminal")
+
+// tuiRow is a row in the TUI, which is either a file or a directory of files.
+type tuiRow struct {
+	Path string
+	Files []int
+	Size int64
+	Rank int
+	Access time.Time
+}
+
+// tuiModel is the state of the TUI.
+type tuiModel struct {
+	Files paths.FilePaths
+	Selected []bool
+	Target int64
+	SortColumn int
+	Reverse bool
+	Filter string
+	Filtering bool
+	ByDir bool
+	Rows []tuiRow
+	Cursor int
+	Offset int
+}
+
+// newTUIModel returns the state of a TUI for choosing from files with every
+// file selected. target is the number of bytes that was asked for.
+func newTUIModel(files paths.FilePaths, target int64) *tuiModel {
+	model := &tuiModel{Files: files, Target: target, Selected: make([]bool, len(files))}
+	for i := range model.Selected {
+		model.Selected[i] = true
+	}
+	model.update()
+	return model
+}
+
+// update recomputes the rows after the filter, sort order or view changes.
+func (m *tuiModel) update() {
+	filter := strings.ToLower(m.Filter)
+	rowIndexes := make(map[string]int)
+	m.Rows = nil
+	for i, filePath := range m.Files {
+		if !strings.Contains(strings.ToLower(filePath.Path), filter) {
+			continue
+		}
+		key := filePath.Path
+		if m.ByDir {
+			key = filepath.Dir(filePath.Path)
+		}
+		j, ok := rowIndexes[key]
+		if !ok {
+			j = len(m.Rows)
+			rowIndexes[key] = j
+			m.Rows = append(m.Rows, tuiRow{Path: key, Rank: filePath.Metadata.Rank})
+		}
+		row := &m.Rows[j]
+		row.Files = append(row.Files, i)
+		row.Size += filePath.Size()
+		if access := filePath.Time.AccessTime(); access.After(row.Access) {
+			row.Access = access
+		}
+	}
+
+	column := tuiSortColumns[m.SortColumn]
+	sort.SliceStable(m.Rows, func(i, j int) bool {
+		a, b := m.Rows[i], m.Rows[j]
+		if m.Reverse {
+			a, b = b, a
+		}
+		switch column {
+		case "size":
+			return a.Size > b.Size
+		case "access":
+			return a.Access.Before(b.Access)
+		case "path":
+			return a.Path < b.Path
+		default:
+			return a.Rank < b.Rank
+		}
+	})
+
+	if m.Cursor >= len(m.Rows) {
+		m.Cursor = len(m.Rows) - 1
+	}
+	if m.Cursor < 0 {
+		m.Cursor = 0
+	}
+}
+
+// rowState returns 'x' if every file in row is selected, '-' if some are and
+// ' ' if none are.
+func (m *tuiModel) rowState(row tuiRow) rune {
+	count := 0
+	for _, i := range row.Files {
+		if m.Selected[i] {
+			count++
+		}
+	}
+	switch count {
+	case len(row.Files):
+		return 'x'
+	case 0:
+		return ' '
+	default:
+		return '-'
+	}
+}
+
+// setRows selects or deselects every file in rows.
+func (m *tuiModel) setRows(rows []tuiRow, selected bool) {
+	for _, row := range rows {
+		for _, i := range row.Files {
+			m.Selected[i] = selected
+		}
+	}
+}
+
+// selection returns the selected files in their original order.
+func (m *tuiModel) selection() paths.FilePaths {
+	var output paths.FilePaths
+	for i, filePath := range m.Files {
+		if m.Selected[i] {
+			output = append(output, filePath)
+		}
+	}
+	return output
+}
+
+// handleKey updates the model after key is pressed with pageSize rows on the
+// screen. It returns true if the user is done, along with whether the
+// selection was confirmed.
+func (m *tuiModel) handleKey(key string, pageSize int) (done bool, confirmed bool) {
+	if m.Filtering {
+		switch key {
+		case "enter":
+			m.Filtering = false
+		case "esc":
+			m.Filtering = false
+			m.Filter = ""
+		case "backspace":
+			if len(m.Filter) > 0 {
+				_, width := utf8.DecodeLastRuneInString(m.Filter)
+				m.Filter = m.Filter[:len(m.Filter) - width]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				m.Filter += key
+			}
+		}
+		m.update()
+		return false, false
+	}
+
+	switch key {
+	case "up", "k":
+		m.Cursor--
+	case "down", "j":
+		m.Cursor++
+	case "pgup":
+		m.Cursor -= pageSize
+	case "pgdn":
+		m.Cursor += pageSize
+	case "home", "g":
+		m.Cursor = 0
+	case "end", "G":
+		m.Cursor = len(m.Rows) - 1
+	case " ":
+		if m.Cursor < len(m.Rows) {
+			row := m.Rows[m.Cursor]
+			m.setRows([]tuiRow{row}, m.rowState(row) != 'x')
+			m.Cursor++
+		}
+	case "a":
+		m.setRows(m.Rows, true)
+	case "n":
+		m.setRows(m.Rows, false)
+	case "s":
+		m.SortColumn = (m.SortColumn + 1) % len(tuiSortColumns)
+		m.update()
+	case "r":
+		m.Reverse = !m.Reverse
+		m.update()
+	case "d":
+		m.ByDir = !m.ByDir
+		m.Cursor = 0
+		m.update()
+	case "/":
+		m.Filtering = true
+	case "esc":
+		if m.Filter != "" {
+			m.Filter = ""
+			m.update()
+		}
+	case "enter":
+		return true, true
+	case "q", "ctrl-c":
+		return true, false
+	}
+
+	if m.Cursor >= len(m.Rows) {
+		m.Cursor = len(m.Rows) - 1
+	}
+	if m.Cursor < 0 {
+		m.Cursor = 0
+	}
+	return false, false
+}
+
+// render returns the text of the screen with the given size.
+func (m *tuiModel) render(width, height int) string {
+	quote := quoteStyles["c"]
+	pageSize := maxInt(height - 4, 1)
+	if m.Cursor < m.Offset {
+		m.Offset = m.Cursor
+	} else if m.Cursor >= m.Offset + pageSize {
+		m.Offset = m.Cursor - pageSize + 1
+	}
+
+	var lines []string
+	lines = append(lines, escBold + fitLine(tuiHelp, width) + escReset)
+	header := "    #      Size      Last Access        Duplicate  Path"
+	if m.ByDir {
+		header = "    Files  Size      Last Access        Directory"
+	}
+	lines = append(lines, fitLine(header, width))
+
+	for i := m.Offset; i < len(m.Rows) && i < m.Offset + pageSize; i++ {
+		row := m.Rows[i]
+		var line string
+		if m.ByDir {
+			line = fmt.Sprintf(
+				"[%c] %-6d %-9s %-18s %s",
+				m.rowState(row), len(row.Files), parse.FormatFileSize(row.Size),
+				row.Access.Format(timeFormat), quote(row.Path + string(filepath.Separator)))
+		} else {
+			filePath := m.Files[row.Files[0]]
+			line = fmt.Sprintf(
+				"[%c] %-6d %-9s %-18s %-10s %s",
+				m.rowState(row), row.Rank, parse.FormatFileSize(row.Size),
+				row.Access.Format(timeFormat), duplicateLabel(filePath), displayPath(filePath, quote))
+		}
+		line = fitLine(line, width)
+		if i == m.Cursor {
+			line = escReverse + line + escReset
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < pageSize + 2 {
+		lines = append(lines, "")
+	}
+
+	var selectedSize int64
+	selected := m.selection()
+	for _, filePath := range selected {
+		selectedSize += filePath.Size()
+	}
+	sortName := tuiSortColumns[m.SortColumn]
+	if m.Reverse {
+		sortName += " (reversed)"
+	}
+	lines = append(lines, fitLine(fmt.Sprintf(
+		"%d of %d selected, %s of %s target  sort: %s",
+		len(selected), len(m.Files), parse.FormatFileSize(selectedSize), parse.FormatFileSize(m.Target), sortName), width))
+	if m.Filtering {
+		lines = append(lines, fitLine("/" + m.Filter, width))
+	} else if m.Filter != "" {
+		lines = append(lines, fitLine(fmt.Sprintf("filter: %s (esc to clear)", m.Filter), width))
+	} else {
+		lines = append(lines, "")
+	}
+
+	return escHome + strings.Join(lines, escClearLine + "\r\n") + escClearLine + escClearBelow
+}
+
+// fitLine truncates line to width characters.
+func fitLine(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:maxInt(width - 1, 0)]) + "…"
+}
+
+// maxInt returns the larger of two integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// parseKeys returns the names of the keys in input read from a terminal in
+// raw mode. Keys with a character are named by that character.
+func parseKeys(input []byte) (keys []string) {
+	for len(input) > 0 {
+		matched := false
+		for sequence, name := range tuiKeySequences {
+			if strings.HasPrefix(string(input), sequence) {
+				keys = append(keys, name)
+				input = input[len(sequence):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		switch input[0] {
+		case '\x1b':
+			input = input[1:]
+			if len(input) == 0 || (input[0] != '[' && input[0] != 'O') {
+				keys = append(keys, "esc")
+				break
+			}
+			// Skip escape sequences which aren't recognized.
+			end := 1
+			for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+				end++
+			}
+			input = input[minInt(end + 1, len(input)):]
+		case '\r', '\n':
+			keys = append(keys, "enter")
+			input = input[1:]
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+			input = input[1:]
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+			input = input[1:]
+		default:
+			char, width := utf8.DecodeRune(input)
+			keys = append(keys, string(char))
+			input = input[width:]
+		}
+	}
+	return keys
+}
+
+// minInt returns the smaller of two integers.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// runTUI shows a full-screen interface for choosing which of files to move
+// and returns the chosen files. target is the number of bytes that was asked
+// for. If the user quits without confirming, confirmed is false.
+func runTUI(files paths.FilePaths, target int64) (selected paths.FilePaths, confirmed bool, err error) {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return nil, false, errNotTerminal
+	}
+
+	oldState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return nil, false, err
+	}
+	defer term.Restore(inFd, oldState)
+	io.WriteString(os.Stdout, escEnterScreen)
+	defer io.WriteString(os.Stdout, escExitScreen)
+
+	model := newTUIModel(files, target)
+	buffer := make([]byte, 256)
+	for {
+		width, height, err := term.GetSize(outFd)
+		if err != nil {
+			return nil, false, err
+		}
+		io.WriteString(os.Stdout, model.render(width, height))
+
+		n, err := os.Stdin.Read(buffer)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, key := range parseKeys(buffer[:n]) {
+			done, confirmed := model.handleKey(key, maxInt(height - 4, 1))
+			if done {
+				return model.selection(), confirmed, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright © 2018 Garrett Powell <garrett@gpowell.net>
+
+This file is part of reddup.
+
+reddup is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+reddup is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with reddup.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	testCases := []struct {
+		TestName string
+		Input string
+		Expected []string
+	}{
+		{"Characters", "jk ", []string{"j", "k", " "}},
+		{"Unicode", "é/", []string{"é", "/"}},
+		{"Arrows", "\x1b[A\x1bOB", []string{"up", "down"}},
+		{"Pages", "\x1b[5~\x1b[6~", []string{"pgup", "pgdn"}},
+		{"Escape", "\x1b", []string{"esc"}},
+		{"Escape then character", "\x1bq", []string{"esc", "q"}},
+		{"Unknown sequence", "\x1b[1;5Cx", []string{"x"}},
+		{"Control keys", "\r\n\x7f\x03", []string{"enter", "enter", "backspace", "ctrl-c"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			keys := parseKeys([]byte(tc.Input))
+			if !reflect.DeepEqual(keys, tc.Expected) {
+				t.Errorf("%q != %q", keys, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestTUIHandleKey(t *testing.T) {
+	files, teardownFunc := setupTestFiles(t, "letters/a.txt", "letters/b.txt", "numbers/1.txt")
+	defer teardownFunc()
+
+	testCases := []struct {
+		TestName string
+		Keys []string
+		ExpectedSelected []bool
+		ExpectedDone bool
+		ExpectedConfirmed bool
+	}{
+		{"Nothing", nil, []bool{true, true, true}, false, false},
+		{"Toggle", []string{" ", " "}, []bool{false, false, true}, false, false},
+		{"Move cursor", []string{"down", "down", " "}, []bool{true, true, false}, false, false},
+		{"Cursor stays in list", []string{"pgdn", "down", " "}, []bool{true, true, false}, false, false},
+		{"End", []string{"end", "up", " "}, []bool{true, false, true}, false, false},
+		{"None and all", []string{"n", "a"}, []bool{true, true, true}, false, false},
+		{"Filter", []string{"/", "n", "u", "m", "enter", "n"}, []bool{true, true, false}, false, false},
+		{"Clear filter", []string{"/", "x", "backspace", "1", "esc", "n"}, []bool{false, false, false}, false, false},
+		{"By directory", []string{"d", " "}, []bool{false, false, true}, false, false},
+		{"Partly selected directory", []string{"down", " ", "d", " "}, []bool{true, true, true}, false, false},
+		{"Reverse", []string{"r", " "}, []bool{true, true, false}, false, false},
+		{"Confirm", []string{"n", "enter", "a"}, []bool{false, false, false}, true, true},
+		{"Quit", []string{"q"}, []bool{true, true, true}, true, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.TestName, func(t *testing.T) {
+			model := newTUIModel(files, 0)
+			var done, confirmed bool
+			for _, key := range tc.Keys {
+				done, confirmed = model.handleKey(key, 2)
+				if done {
+					break
+				}
+			}
+
+			if !reflect.DeepEqual(model.Selected, tc.ExpectedSelected) {
+				t.Errorf("selected %v != %v", model.Selected, tc.ExpectedSelected)
+			}
+			if done != tc.ExpectedDone || confirmed != tc.ExpectedConfirmed {
+				t.Errorf("done %v, confirmed %v != %v, %v", done, confirmed, tc.ExpectedDone, tc.ExpectedConfirmed)
+			}
+		})
+	}
+}